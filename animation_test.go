@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestAnimation animates 100 turns of the 64x64 image with a frame every 50 turns, as a GIF and as an APNG.
+// The frames should be drawn twice the size in the given colours and match the expected images.
+func TestAnimation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animation")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	alive := gol.Colour{R: 255}
+	dead := gol.Colour{B: 64}
+	for _, extension := range []string{".gif", ".png"} {
+		t.Run(extension, func(t *testing.T) {
+			p := gol.Params{
+				ImageWidth:   64,
+				ImageHeight:  64,
+				Turns:        100,
+				Threads:      4,
+				Output:       dir + "/",
+				Animation:    filepath.Join(dir, "life"+extension),
+				AnimateEvery: 50,
+				AnimateScale: 2,
+				AliveColour:  alive,
+				DeadColour:   dead,
+			}
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			written := false
+			for event := range events {
+				if output, ok := event.(gol.ImageOutputComplete); ok && output.Filename == p.Animation {
+					written = true
+				}
+			}
+			if !written {
+				t.Fatalf("Expected an ImageOutputComplete event for %v", p.Animation)
+			}
+
+			data, err := ioutil.ReadFile(p.Animation)
+			util.Check(err)
+			if extension == ".gif" {
+				animation, err := gif.DecodeAll(bytes.NewReader(data))
+				util.Check(err)
+				if len(animation.Image) != 3 {
+					t.Fatalf("Expected 3 frames, got %v", len(animation.Image))
+				}
+				checkFrame(t, animation.Image[0], "check/images/64x64x0.pgm", alive, dead)
+				checkFrame(t, animation.Image[2], "check/images/64x64x100.pgm", alive, dead)
+				return
+			}
+
+			frames := bytes.Index(data, []byte("acTL"))
+			if frames < 0 || binary.BigEndian.Uint32(data[frames+4:]) != 3 {
+				t.Fatal("Expected an APNG with 3 frames")
+			}
+			// Viewers without APNG support show the first frame.
+			first, err := png.Decode(bytes.NewReader(data))
+			util.Check(err)
+			checkFrame(t, first, "check/images/64x64x0.pgm", alive, dead)
+
+			images := apngFrames(t, data)
+			if len(images) != 3 {
+				t.Fatalf("Expected 3 frames, got %v", len(images))
+			}
+			checkFrame(t, images[0], "check/images/64x64x0.pgm", alive, dead)
+			checkFrame(t, images[2], "check/images/64x64x100.pgm", alive, dead)
+		})
+	}
+}
+
+// TestAnimationLimit animates every turn of a glider in a 4096x4096 world.
+// Only 4 frames fit in the cells that an animation can hold, so it should be written out after the 4th frame.
+func TestAnimationLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limit")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	pattern := filepath.Join(dir, "glider.cells")
+	util.Check(ioutil.WriteFile(pattern, []byte(".O.\n..O\nOOO\n"), 0644))
+	p := gol.Params{
+		ImageWidth:  4096,
+		ImageHeight: 4096,
+		Turns:       4,
+		Threads:     4,
+		Pattern:     pattern,
+		Events:      gol.StateEvents,
+		Output:      dir + "/",
+		Animation:   filepath.Join(dir, "glider{turn}.gif"),
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var animations []string
+	for event := range events {
+		if output, ok := event.(gol.ImageOutputComplete); ok && strings.HasSuffix(output.Filename, ".gif") {
+			animations = append(animations, output.Filename)
+		}
+	}
+	expected := filepath.Join(dir, "glider3.gif")
+	if len(animations) != 1 || animations[0] != expected {
+		t.Fatalf("Expected the animation to be written to %v once, got %v", expected, animations)
+	}
+	file, err := os.Open(expected)
+	util.Check(err)
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	util.Check(err)
+	if len(animation.Image) != 4 {
+		t.Errorf("Expected 4 frames, got %v", len(animation.Image))
+	}
+}
+
+// TestAnimateCommand pauses a run, starts an animation with 'g', steps 4 turns and finishes it with 'g' again.
+func TestAnimateCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animate")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	if _, ok := gol.KeyCommand('g').(gol.Animate); !ok {
+		t.Error("Expected 'g' to animate")
+	}
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4, Output: dir + "/", AnimateEvery: 2}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	reply := make(chan gol.Ack, 1)
+	go gol.RunWithCommands(p, events, commands)
+
+	script := []gol.Command{
+		gol.Pause{Reply: reply},
+		gol.Animate{Reply: reply},
+		gol.Step{Turns: 4, Reply: reply},
+		gol.Animate{Reply: reply},
+		gol.Quit{Reply: reply},
+	}
+	commands <- script[0]
+	var acks []gol.Ack
+	var animations []string
+	for events != nil {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				break
+			}
+			if output, ok := event.(gol.ImageOutputComplete); ok && strings.HasSuffix(output.Filename, ".gif") {
+				animations = append(animations, output.Filename)
+			}
+		case ack := <-reply:
+			acks = append(acks, ack)
+			if len(acks) < len(script) {
+				commands <- script[len(acks)]
+			}
+		}
+	}
+	if len(acks) < len(script) {
+		acks = append(acks, <-reply)
+	}
+
+	for i, ack := range acks {
+		if ack.Err != nil {
+			t.Errorf("Expected %T to succeed, got %v", script[i], ack.Err)
+		}
+	}
+	if len(animations) != 1 {
+		t.Fatalf("Expected one animation to be written, got %v", animations)
+	}
+	expected := filepath.Join(dir, "64x64x"+strconv.Itoa(acks[3].CompletedTurns)+".gif")
+	if animations[0] != expected {
+		t.Errorf("Expected the animation to be written to %v, got %v", expected, animations[0])
+	}
+	file, err := os.Open(animations[0])
+	util.Check(err)
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	util.Check(err)
+	if len(animation.Image) != 3 {
+		t.Errorf("Expected a frame every 2 of the 4 turns and one at the start, got %v frames", len(animation.Image))
+	}
+}
+
+// checkFrame checks that every cell of a frame drawn at twice the size has the colour of the cell in the expected image.
+func checkFrame(t *testing.T, frame image.Image, expectedPath string, alive, dead gol.Colour) {
+	expected := make(map[util.Cell]bool)
+	for _, cell := range readAliveCells(expectedPath, 64, 64) {
+		expected[cell] = true
+	}
+	if bounds := frame.Bounds(); bounds.Dx() != 128 || bounds.Dy() != 128 {
+		t.Fatalf("Expected a 128x128 frame, got %vx%v", bounds.Dx(), bounds.Dy())
+	}
+	aliveColour := color.RGBA{alive.R, alive.G, alive.B, 255}
+	deadColour := color.RGBA{dead.R, dead.G, dead.B, 255}
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			want := deadColour
+			if expected[util.Cell{X: x / 2, Y: y / 2}] {
+				want = aliveColour
+			}
+			if got := color.RGBAModel.Convert(frame.At(x, y)); got != want {
+				t.Fatalf("Expected pixel (%v, %v) of the frame for %v to be %v, got %v", x, y, expectedPath, want, got)
+			}
+		}
+	}
+}
+
+// apngFrames checks that the frame control and frame data chunks of an APNG are numbered in order,
+// and returns each frame decoded as a PNG on its own.
+func apngFrames(t *testing.T, data []byte) []image.Image {
+	var header, palette []byte
+	var frames [][]byte
+	sequence := uint32(0)
+	for rest := data[8:]; len(rest) >= 12; {
+		length := binary.BigEndian.Uint32(rest)
+		kind, chunk := string(rest[4:8]), rest[8:8+length]
+		rest = rest[12+length:]
+		switch kind {
+		case "IHDR":
+			header = chunk
+		case "PLTE":
+			palette = chunk
+		case "fcTL", "fdAT":
+			if got := binary.BigEndian.Uint32(chunk); got != sequence {
+				t.Fatalf("Expected %v chunk %v to have sequence number %v, got %v", kind, len(frames), sequence, got)
+			}
+			sequence++
+			if kind == "fcTL" {
+				frames = append(frames, nil)
+			} else {
+				frames[len(frames)-1] = append(frames[len(frames)-1], chunk[4:]...)
+			}
+		case "IDAT":
+			frames[len(frames)-1] = append(frames[len(frames)-1], chunk...)
+		}
+	}
+
+	var images []image.Image
+	for _, frame := range frames {
+		var encoded bytes.Buffer
+		encoded.Write(data[:8])
+		writeChunk(&encoded, "IHDR", header)
+		if palette != nil {
+			writeChunk(&encoded, "PLTE", palette)
+		}
+		writeChunk(&encoded, "IDAT", frame)
+		writeChunk(&encoded, "IEND", nil)
+		decoded, err := png.Decode(&encoded)
+		if err != nil {
+			t.Fatalf("Frame %v of the APNG cannot be decoded: %v", len(images), err)
+		}
+		images = append(images, decoded)
+	}
+	return images
+}
+
+func writeChunk(w *bytes.Buffer, kind string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+	w.WriteString(kind)
+	w.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(append([]byte(kind), data...)))
+	w.Write(sum[:])
+}
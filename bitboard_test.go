@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBitboard tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using the bitboard backend with 1, 5 and 16 worker threads.
+func TestBitboard(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Backend: gol.Bitboard},
+		{ImageWidth: 64, ImageHeight: 64, Backend: gol.Bitboard},
+		{ImageWidth: 512, ImageHeight: 512, Backend: gol.Bitboard},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			for _, threads := range []int{1, 5, 16} {
+				p.Threads = threads
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				t.Run(testName, func(t *testing.T) {
+					assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+				})
+			}
+		}
+	}
+}
+
+// TestBitboardRules tests that the bitboard backend agrees with the strips backend on other Life-like rules.
+func TestBitboardRules(t *testing.T) {
+	for _, rulestring := range []string{"B36/S23", "B2/S", "B3678/S34678", "B0/S8"} {
+		rule, err := gol.ParseRule(rulestring)
+		util.Check(err)
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 50, Threads: 4, Rule: rule}
+		expectedAlive := finalAlive(p)
+		p.Backend = gol.Bitboard
+		t.Run(rulestring, func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+		})
+	}
+}
+
+// finalAlive runs the Game of Life and returns the alive cells from the FinalTurnComplete event.
+func finalAlive(p gol.Params) []util.Cell {
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	return cells
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// main starts a broker with 'go run ./broker -workers 127.0.0.1:8040,127.0.0.1:8041'
+func main() {
+	port := flag.String(
+		"port",
+		"8030",
+		"Specify the port to listen on for the local controller. Defaults to 8030.")
+
+	workers := flag.String(
+		"workers",
+		"127.0.0.1:8040",
+		"Specify a comma separated list of worker server addresses. Defaults to 127.0.0.1:8040.")
+
+	timeout := flag.Duration(
+		"timeout",
+		gol.DefaultWorkerTimeout,
+		"Specify how long a worker has to reply before its strip is given to the other workers. Defaults to 10s.")
+
+	snapshotEvery := flag.Int(
+		"snapshot",
+		gol.DefaultSnapshotEvery,
+		"Specify how many turns to process between putting the world back together from the workers, which is where the workers that are left carry on from when one fails. Defaults to 100.")
+
+	attachTimeout := flag.Duration(
+		"attachTimeout",
+		gol.DefaultAttachTimeout,
+		"Specify how long a controller that attached has to ask for a turn before the run is carried on without it, so that another can attach. Defaults to 1m.")
+
+	flag.Parse()
+
+	broker, err := gol.NewBroker(strings.Split(*workers, ","))
+	util.Check(err)
+	defer broker.Close()
+	broker.Timeout = *timeout
+	broker.SnapshotEvery = *snapshotEvery
+	broker.AttachTimeout = *attachTimeout
+
+	err = rpc.Register(broker)
+	util.Check(err)
+	listener, err := net.Listen("tcp", ":"+*port)
+	util.Check(err)
+
+	fmt.Println("Broker listening on port", *port)
+	go rpc.Accept(listener)
+
+	// The broker exits once a controller shuts it down with 'k', after its workers have been told to exit.
+	<-broker.Done()
+	fmt.Println("Broker shut down")
+}
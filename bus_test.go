@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestBus runs 100 turns of the 64x64 image with three subscribers to a bus.
+// One blocks and reads every event, while the others only start reading once the run has finished:
+// one drops the oldest events and the other coalesces them, and neither should hold up the run.
+func TestBus(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	bus := gol.NewBus()
+	every := bus.Subscribe(1, gol.Block)
+	dropped := bus.Subscribe(4, gol.DropOldest)
+	coalesced := bus.Subscribe(8, gol.Coalesce)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- gol.Run(p, bus.Events(), nil)
+	}()
+
+	turns := 0
+	for event := range every {
+		if _, ok := event.(gol.TurnComplete); ok {
+			turns++
+		}
+	}
+	if turns != p.Turns {
+		t.Errorf("Expected every TurnComplete event, got %v", turns)
+	}
+	select {
+	case err := <-runErr:
+		util.Check(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the run to finish without the other subscribers reading")
+	}
+
+	var last []gol.Event
+	for event := range dropped {
+		last = append(last, event)
+	}
+	if len(last) > 5 {
+		t.Errorf("Expected at most 5 events to be kept, got %v", len(last))
+	}
+	if state, ok := last[len(last)-1].(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("Expected the Quitting StateChange to be kept, got %v", last)
+	}
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	alive := make(map[util.Cell]bool)
+	lastTurn := 0
+	for event := range coalesced {
+		switch event := event.(type) {
+		case gol.CellsFlipped:
+			for _, cell := range event.Cells {
+				alive[cell] = !alive[cell]
+			}
+		case gol.TurnComplete:
+			lastTurn = event.CompletedTurns
+		}
+	}
+	if lastTurn != p.Turns {
+		t.Errorf("Expected the last TurnComplete to be kept, got turn %v", lastTurn)
+	}
+	var cells []util.Cell
+	for cell, isAlive := range alive {
+		if isAlive {
+			cells = append(cells, cell)
+		}
+	}
+	assertEqualBoard(t, cells, expectedAlive, p)
+}
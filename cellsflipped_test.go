@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestCellsFlipped runs 100 turns of the 64x64 image on each backend with 4 threads,
+// building the board from CellsFlipped events, and from CellFlipped events when they are asked for instead.
+// At most one CellsFlipped event should be sent by each worker every turn.
+func TestCellsFlipped(t *testing.T) {
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	for _, backend := range []gol.Backend{gol.Strips, gol.Bitboard, gol.HashLife} {
+		for _, perCell := range []bool{false, true} {
+			p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, Backend: backend, CellFlippedEvents: perCell}
+			t.Run(fmt.Sprintf("%v-%v", backend, perCell), func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+
+				alive := make(map[util.Cell]bool)
+				// The first batch holds the cells alive when the image is loaded in, before any worker sends one.
+				batches := map[int]int{0: -1}
+				for event := range events {
+					switch event := event.(type) {
+					case gol.CellsFlipped:
+						if perCell {
+							t.Fatal("Expected only CellFlipped events")
+						}
+						batches[event.CompletedTurns]++
+						for _, cell := range event.Cells {
+							alive[cell] = !alive[cell]
+						}
+					case gol.CellFlipped:
+						if !perCell {
+							t.Fatal("Expected only CellsFlipped events")
+						}
+						alive[event.Cell] = !alive[event.Cell]
+					}
+				}
+
+				for turn, n := range batches {
+					if n > p.Threads {
+						t.Errorf("Expected at most %v CellsFlipped events after turn %v, got %v", p.Threads, turn, n)
+					}
+				}
+				var cells []util.Cell
+				for cell, isAlive := range alive {
+					if isAlive {
+						cells = append(cells, cell)
+					}
+				}
+				assertEqualBoard(t, cells, expectedAlive, p)
+			})
+		}
+	}
+}
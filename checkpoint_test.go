@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCheckpoint saves checkpoints every 30 turns of a 100 turn run on 16x16 and 64x64 images,
+// then resumes from the last one at turn 90 and checks that the run finishes with the expected board.
+func TestCheckpoint(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4},
+	}
+	for _, p := range tests {
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+		t.Run(testName, func(t *testing.T) {
+			p.CheckpointEvery = 30
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var saved []int
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CheckpointOutputComplete:
+					saved = append(saved, e.CompletedTurns)
+				}
+			}
+			if fmt.Sprint(saved) != "[30 60 90]" {
+				t.Fatalf("Expected checkpoints after turns [30 60 90], got %v instead", saved)
+			}
+
+			resumed := gol.Params{
+				Threads: 3,
+				Resume:  fmt.Sprintf("out/%vx%v.checkpoint", p.ImageWidth, p.ImageHeight),
+			}
+			events = make(chan gol.Event)
+			go gol.Run(resumed, events, nil)
+			turns := 0
+			for event := range events {
+				switch e := event.(type) {
+				case gol.TurnComplete:
+					turns++
+				case gol.FinalTurnComplete:
+					if e.CompletedTurns != p.Turns {
+						t.Errorf("Expected the resumed run to finish after turn %v, got %v instead", p.Turns, e.CompletedTurns)
+					}
+					assertEqualBoard(t, e.Alive, expectedAlive, p)
+				}
+			}
+			if turns != 10 {
+				t.Errorf("Expected the resumed run to process 10 turns, got %v instead", turns)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCommands pauses a run, steps it, limits its speed and quits it with commands,
+// checking the turn that each command is acknowledged at.
+func TestCommands(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 2}
+	events := make(chan gol.Event, 1000)
+	commands := make(chan gol.Command)
+	go gol.RunWithCommands(p, events, commands)
+	go func() {
+		for range events {
+		}
+	}()
+
+	reply := make(chan gol.Ack, 1)
+	apply := func(command gol.Command) gol.Ack {
+		commands <- command
+		select {
+		case ack := <-reply:
+			return ack
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%T was not acknowledged", command)
+			return gol.Ack{}
+		}
+	}
+
+	paused := apply(gol.Pause{Reply: reply})
+	stepped := apply(gol.Step{Turns: 5, Reply: reply})
+	if stepped.CompletedTurns != paused.CompletedTurns+5 {
+		t.Fatalf("Expected stepping 5 turns from turn %v to reach turn %v, got %v", paused.CompletedTurns, paused.CompletedTurns+5, stepped.CompletedTurns)
+	}
+	time.Sleep(100 * time.Millisecond)
+	saved := apply(gol.Save{Reply: reply})
+	if saved.Err != nil || saved.CompletedTurns != stepped.CompletedTurns {
+		t.Fatalf("Expected to save at turn %v while paused, got turn %v and error %v", stepped.CompletedTurns, saved.CompletedTurns, saved.Err)
+	}
+
+	apply(gol.SetSpeed{TurnsPerSecond: 20, Reply: reply})
+	resumed := apply(gol.Resume{Reply: reply})
+	time.Sleep(500 * time.Millisecond)
+	quit := apply(gol.Quit{Reply: reply})
+	if turns := quit.CompletedTurns - resumed.CompletedTurns; turns < 3 || turns > 15 {
+		t.Fatalf("Expected about 10 turns in half a second at 20 turns per second, got %v", turns)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestDetach detaches from a run on a broker after about 10 turns, then attaches a new controller to it,
+// checking that the new controller carries on from where the broker got to and finishes with the right board.
+// The new controller loads its details first like main does, which should only attach once,
+// and attaching again once the run is no longer detached should fail.
+func TestDetach(t *testing.T) {
+	var addresses []string
+	for i := 0; i < 2; i++ {
+		listener := serveRpc(&gol.WorkerServer{})
+		defer listener.Close()
+		addresses = append(addresses, listener.Addr().String())
+	}
+	broker, err := gol.NewBroker(addresses)
+	util.Check(err)
+	defer broker.Close()
+	brokerListener := serveRpc(broker)
+	defer brokerListener.Close()
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Broker: brokerListener.Addr().String()}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	go gol.RunWithCommands(p, events, commands)
+
+	reply := make(chan gol.Ack, 1)
+	for event := range events {
+		switch event.(type) {
+		case gol.TurnComplete:
+			if event.GetCompletedTurns() == 10 {
+				commands <- gol.Detach{Reply: reply}
+			}
+		case gol.FinalTurnComplete, gol.ImageOutputComplete:
+			t.Fatalf("Expected nothing to be written out when detaching, got %v", event)
+		}
+	}
+	ack := <-reply
+	if ack.Err != nil {
+		t.Fatal(ack.Err)
+	}
+	if ack.CompletedTurns < 10 {
+		t.Fatalf("Expected to detach after at least 10 turns, got %v", ack.CompletedTurns)
+	}
+
+	loaded, start, err := gol.LoadParams(gol.Params{Broker: p.Broker, Attach: true})
+	util.Check(err)
+	if loaded.ImageWidth != 64 || loaded.ImageHeight != 64 || loaded.Turns != 100 {
+		t.Fatalf("Expected to attach to a 64x64 run of 100 turns, got %vx%v and %v turns", loaded.ImageWidth, loaded.ImageHeight, loaded.Turns)
+	}
+	attached := make(chan gol.Event)
+	go gol.RunFrom(loaded, start, attached, nil)
+	first := -1
+	var final gol.FinalTurnComplete
+	for event := range attached {
+		switch event := event.(type) {
+		case gol.CellsFlipped:
+			if first == -1 {
+				first = event.CompletedTurns
+			}
+		case gol.FinalTurnComplete:
+			final = event
+		}
+	}
+	if first < ack.CompletedTurns {
+		t.Fatalf("Expected the attached controller to start from turn %v or later, got %v", ack.CompletedTurns, first)
+	}
+	if final.CompletedTurns != 100 {
+		t.Fatalf("Expected the attached controller to finish after 100 turns, got %v", final.CompletedTurns)
+	}
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	assertEqualBoard(t, final.Alive, expectedAlive, p)
+	if _, _, err := gol.LoadParams(gol.Params{Broker: p.Broker, Attach: true}); err == nil {
+		t.Fatal("Expected an error when attaching to a run that has not been detached from")
+	}
+
+	events = make(chan gol.Event)
+	commands = make(chan gol.Command, 2)
+	commands <- gol.Detach{Reply: reply}
+	commands <- gol.Quit{}
+	go gol.RunWithCommands(gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000}, events, commands)
+	for range events {
+	}
+	if ack := <-reply; ack.Err == nil {
+		t.Fatal("Expected an error when detaching from a local run")
+	}
+}
+
+// TestAttachTimeout attaches to a detached run and then goes quiet, as a controller that died would.
+// The run should not be attached to again until the lease has run out, and should carry on in the background after it.
+func TestAttachTimeout(t *testing.T) {
+	listener := serveRpc(&gol.WorkerServer{})
+	defer listener.Close()
+	broker, err := gol.NewBroker([]string{listener.Addr().String()})
+	util.Check(err)
+	defer broker.Close()
+	broker.AttachTimeout = 200 * time.Millisecond
+	brokerListener := serveRpc(broker)
+	defer brokerListener.Close()
+
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1000000000, Broker: brokerListener.Addr().String()}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	reply := make(chan gol.Ack, 1)
+	commands <- gol.Detach{Reply: reply}
+	go gol.RunWithCommands(p, events, commands)
+	for range events {
+	}
+	util.Check((<-reply).Err)
+
+	attach := gol.Params{Broker: p.Broker, Attach: true}
+	_, first, err := gol.LoadParams(attach)
+	util.Check(err)
+	if _, _, err := gol.LoadParams(attach); err == nil {
+		t.Fatal("Expected an error when attaching to a run that has just been attached to")
+	}
+	time.Sleep(500 * time.Millisecond)
+	_, second, err := gol.LoadParams(attach)
+	if err != nil {
+		t.Fatalf("Expected to attach again once the lease had run out, got %v", err)
+	}
+	if second.CompletedTurns <= first.CompletedTurns {
+		t.Errorf("Expected the run to carry on after turn %v once the lease had run out, got turn %v", first.CompletedTurns, second.CompletedTurns)
+	}
+}
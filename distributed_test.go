@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestDistributed tests 16x16 and 64x64 images on 0, 1 and 100 turns using a broker with 1 and 4 worker servers.
+func TestDistributed(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, servers := range []int{1, 4} {
+		var workers []string
+		var listeners []net.Listener
+		for i := 0; i < servers; i++ {
+			listener := serveRpc(&gol.WorkerServer{})
+			workers = append(workers, listener.Addr().String())
+			listeners = append(listeners, listener)
+		}
+		broker, err := gol.NewBroker(workers)
+		util.Check(err)
+		brokerListener := serveRpc(broker)
+		listeners = append(listeners, brokerListener)
+
+		for _, p := range tests {
+			p.Broker = brokerListener.Addr().String()
+			for _, turns := range []int{0, 1, 100} {
+				p.Turns = turns
+				expectedAlive := readAliveCells(
+					"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+					p.ImageWidth,
+					p.ImageHeight,
+				)
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, servers)
+				t.Run(testName, func(t *testing.T) {
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					var cells []util.Cell
+					for event := range events {
+						switch e := event.(type) {
+						case gol.FinalTurnComplete:
+							cells = e.Alive
+						}
+					}
+					assertEqualBoard(t, cells, expectedAlive, p)
+				})
+			}
+		}
+		broker.Close()
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+}
+
+// serveRpc registers rcvr with a new RPC server listening on a free local port.
+func serveRpc(rcvr interface{}) net.Listener {
+	server := rpc.NewServer()
+	util.Check(server.Register(rcvr))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Check(err)
+	go server.Accept(listener)
+	return listener
+}
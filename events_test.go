@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestEvents runs 100 turns of the 64x64 image locally and on a broker, only asking for StateEvents and TurnEvents,
+// checking that no other kinds of events are sent and that the final board is still right.
+// The worker should never be asked to keep track of the cells that change.
+func TestEvents(t *testing.T) {
+	tracking := &trackingWorker{WorkerServer: &gol.WorkerServer{}}
+	server := rpc.NewServer()
+	util.Check(server.RegisterName("WorkerServer", tracking))
+	worker, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Check(err)
+	go server.Accept(worker)
+	defer worker.Close()
+	broker, err := gol.NewBroker([]string{worker.Addr().String()})
+	util.Check(err)
+	defer broker.Close()
+	brokerListener := serveRpc(broker)
+	defer brokerListener.Close()
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	for _, address := range []string{"", brokerListener.Addr().String()} {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, Broker: address, ReportEvery: 1,
+			Events: gol.StateEvents | gol.TurnEvents}
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+
+		turns := 0
+		var final []util.Cell
+		for event := range events {
+			switch event := event.(type) {
+			case gol.CellsFlipped, gol.CellFlipped, gol.AliveCellsCount, gol.StatsReport:
+				t.Fatalf("Expected no %T events", event)
+			case gol.TurnComplete:
+				turns++
+			case gol.FinalTurnComplete:
+				final = event.Alive
+			}
+		}
+		if turns != p.Turns {
+			t.Errorf("Expected %v TurnComplete events, got %v", p.Turns, turns)
+		}
+		assertEqualBoard(t, final, expectedAlive, p)
+	}
+	if steps := atomic.LoadInt32(&tracking.steps); steps != 0 {
+		t.Errorf("Expected the worker not to keep track of the cells that change, got %v steps that did", steps)
+	}
+}
+
+// trackingWorker is a worker server that counts the steps it is asked to keep track of the cells that change in.
+type trackingWorker struct {
+	*gol.WorkerServer
+	steps int32
+}
+
+func (w *trackingWorker) Step(req gol.StepRequest, res *gol.StepResponse) error {
+	if !req.SkipFlipped {
+		atomic.AddInt32(&w.steps, 1)
+	}
+	return w.WorkerServer.Step(req, res)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestFaultTolerance runs 100 turns on a broker with three worker servers, making one of them fail after 30 turns,
+// either by disappearing or by no longer replying. The board should still be right, and the lost worker reported.
+// The other workers carry on from a snapshot taken every 7 turns, or from the start of the run.
+func TestFaultTolerance(t *testing.T) {
+	for _, test := range []struct {
+		fail          string
+		snapshotEvery int
+	}{{"disappear", 7}, {"stall", 7}, {"disappear", 100}} {
+		fail := test.fail
+		t.Run(fmt.Sprintf("%v-%v", fail, test.snapshotEvery), func(t *testing.T) {
+			var listeners []*flakyListener
+			var addresses []string
+			for i := 0; i < 3; i++ {
+				listener := serveFlakyWorker()
+				defer listener.kill()
+				listeners = append(listeners, listener)
+				addresses = append(addresses, listener.Addr().String())
+			}
+			broker, err := gol.NewBroker(addresses)
+			util.Check(err)
+			defer broker.Close()
+			broker.Timeout = 200 * time.Millisecond
+			broker.SnapshotEvery = test.snapshotEvery
+			brokerListener := serveRpc(broker)
+			defer brokerListener.Close()
+
+			p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Broker: brokerListener.Addr().String()}
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+
+			var lost []gol.WorkersLost
+			var final gol.FinalTurnComplete
+			for event := range events {
+				switch event := event.(type) {
+				case gol.TurnComplete:
+					if event.CompletedTurns == 30 {
+						if fail == "disappear" {
+							listeners[1].kill()
+						} else {
+							listeners[1].stall()
+						}
+					}
+				case gol.WorkersLost:
+					lost = append(lost, event)
+				case gol.FinalTurnComplete:
+					final = event
+				}
+			}
+
+			if len(lost) != 1 || len(lost[0].Lost) != 1 || lost[0].Lost[0] != addresses[1] || lost[0].Workers != 2 {
+				t.Fatalf("Expected worker %v to be reported lost with 2 workers left, got %v", addresses[1], lost)
+			}
+			if lost[0].CompletedTurns < 30 {
+				t.Errorf("Expected the worker to be lost after at least 30 turns, got %v", lost[0].CompletedTurns)
+			}
+			expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+			assertEqualBoard(t, final.Alive, expectedAlive, p)
+		})
+	}
+}
+
+// TestBrokerLost runs on a broker that cannot be reached, and on one that disappears after 30 turns.
+// The run should end with an error from gol.Run instead of crashing the controller.
+func TestBrokerLost(t *testing.T) {
+	t.Run("unreachable", func(t *testing.T) {
+		listener := serveRpc(&gol.WorkerServer{})
+		address := listener.Addr().String()
+		util.Check(listener.Close())
+
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Broker: address}
+		events := make(chan gol.Event)
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- gol.Run(p, events, nil)
+		}()
+		for range events {
+		}
+		if err := <-runErr; err == nil {
+			t.Error("Expected an error when the broker cannot be reached")
+		}
+	})
+
+	t.Run("disappear", func(t *testing.T) {
+		worker := serveFlakyWorker()
+		defer worker.kill()
+		broker, err := gol.NewBroker([]string{worker.Addr().String()})
+		util.Check(err)
+		defer broker.Close()
+		brokerListener := serveFlaky(broker)
+		defer brokerListener.kill()
+
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Broker: brokerListener.Addr().String()}
+		events := make(chan gol.Event)
+		runErr := make(chan error, 1)
+		go func() {
+			runErr <- gol.Run(p, events, nil)
+		}()
+		for event := range events {
+			if turn, ok := event.(gol.TurnComplete); ok && turn.CompletedTurns == 30 {
+				brokerListener.kill()
+			}
+		}
+		select {
+		case err := <-runErr:
+			if err == nil {
+				t.Error("Expected an error when the broker disappears")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the run to end when the broker disappears")
+		}
+	})
+}
+
+// serveFlakyWorker serves a new worker server on a free local port.
+func serveFlakyWorker() *flakyListener {
+	return serveFlaky(&gol.WorkerServer{})
+}
+
+// serveFlaky serves rcvr on a free local port, behind a listener that can make it disappear or stall.
+func serveFlaky(rcvr interface{}) *flakyListener {
+	server := rpc.NewServer()
+	util.Check(server.Register(rcvr))
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Check(err)
+	listener := &flakyListener{Listener: inner, stalled: make(chan struct{}), killed: make(chan struct{})}
+	go server.Accept(listener)
+	return listener
+}
+
+// flakyListener serves a worker that can be made to disappear, by closing all of its connections,
+// or to stall, by never sending another reply.
+type flakyListener struct {
+	net.Listener
+	mu      sync.Mutex
+	conns   []net.Conn
+	stalled chan struct{}
+	killed  chan struct{}
+	killing sync.Once
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conn = &flakyConn{Conn: conn, stalled: l.stalled, killed: l.killed}
+	l.conns = append(l.conns, conn)
+	return conn, nil
+}
+
+func (l *flakyListener) stall() {
+	close(l.stalled)
+}
+
+func (l *flakyListener) kill() {
+	l.killing.Do(func() {
+		close(l.killed)
+		_ = l.Listener.Close()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, conn := range l.conns {
+			_ = conn.Close()
+		}
+	})
+}
+
+// flakyConn stops writing once stalled is closed, and waits until it is killed instead.
+type flakyConn struct {
+	net.Conn
+	stalled <-chan struct{}
+	killed  <-chan struct{}
+}
+
+func (c *flakyConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.stalled:
+		<-c.killed
+		return 0, net.ErrClosed
+	default:
+		return c.Conn.Write(b)
+	}
+}
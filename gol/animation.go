@@ -0,0 +1,323 @@
+package gol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Colour is the colour that alive or dead cells are drawn in an animation.
+type Colour struct {
+	R, G, B uint8
+}
+
+var colourNames = map[string]Colour{
+	"black":   {0, 0, 0},
+	"white":   {255, 255, 255},
+	"grey":    {128, 128, 128},
+	"red":     {255, 0, 0},
+	"green":   {0, 255, 0},
+	"blue":    {0, 0, 255},
+	"yellow":  {255, 255, 0},
+	"cyan":    {0, 255, 255},
+	"magenta": {255, 0, 255},
+}
+
+// ParseColour parses a colour written as #rrggbb, or one of black, white, grey, red, green, blue, yellow, cyan or magenta.
+func ParseColour(name string) (Colour, error) {
+	if colour, ok := colourNames[strings.ToLower(name)]; ok {
+		return colour, nil
+	}
+	hex := strings.TrimPrefix(name, "#")
+	if len(hex) == 6 {
+		if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return Colour{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb)}, nil
+		}
+	}
+	return Colour{}, errors.New("unknown colour " + strconv.Quote(name) + ", should be #rrggbb or a name such as black or white")
+}
+
+func (c Colour) String() string {
+	for name, colour := range colourNames {
+		if colour == c {
+			return name
+		}
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Set allows a Colour to be used as a command line flag.
+func (c *Colour) Set(name string) error {
+	colour, err := ParseColour(name)
+	if err != nil {
+		return err
+	}
+	*c = colour
+	return nil
+}
+
+// animationDelay is how long each frame of an animation is shown for, in hundredths of a second.
+const animationDelay = 10
+
+// maxAnimationCells is the most cells that the frames of an animation can hold between them,
+// so that an animation of a long run does not use up all of the memory. It is 64 MiB, as every cell takes a byte.
+const maxAnimationCells = 1 << 26
+
+// animation holds the frames of an animation while it is being made.
+// A frame is added every few turns from the first turn until the last one, or until it is finished when last is 0.
+// The animation is also finished once it holds maxFrames frames.
+// The frames are kept with a pixel for every cell, and are only scaled up when they are written out.
+type animation struct {
+	first, last, every int
+	scale              int
+	palette            color.Palette
+	frames             []*image.Paletted
+	maxFrames          int
+
+	// turn is the turn of the last frame added.
+	turn int
+}
+
+func newAnimation(p Params, first, last int) *animation {
+	a := &animation{first: first, last: last, every: p.AnimateEvery, scale: p.AnimateScale}
+	if a.every <= 0 {
+		a.every = 1
+	}
+	if a.scale <= 0 {
+		a.scale = 1
+	}
+	alive, dead := p.AliveColour, p.DeadColour
+	if alive == dead {
+		alive, dead = colourNames["white"], colourNames["black"]
+	}
+	a.maxFrames = maxAnimationCells / (p.ImageWidth * p.ImageHeight)
+	if a.maxFrames < 1 {
+		a.maxFrames = 1
+	}
+	a.palette = color.Palette{
+		color.RGBA{dead.R, dead.G, dead.B, 255},
+		color.RGBA{alive.R, alive.G, alive.B, 255},
+	}
+	return a
+}
+
+// due reports whether a frame should be added after the given turn.
+func (a *animation) due(turn int) bool {
+	return turn >= a.first && (a.last == 0 || turn <= a.last) && (turn-a.first)%a.every == 0
+}
+
+// finished reports whether every frame up to the last turn has been added, or there is no room for any more.
+func (a *animation) finished(turn int) bool {
+	return a.last > 0 && turn >= a.last || len(a.frames) >= a.maxFrames
+}
+
+// turnsUntilFrame returns how many turns can be processed before the next frame is due.
+func (a *animation) turnsUntilFrame(turn int) int {
+	if turn < a.first {
+		return a.first - turn
+	}
+	return a.every - (turn-a.first)%a.every
+}
+
+// add draws the world as the next frame.
+func (a *animation) add(turn int, world [][]uint8) {
+	height, width := len(world), len(world[0])
+	frame := image.NewPaletted(image.Rect(0, 0, width, height), a.palette)
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 255 {
+				frame.Pix[y*frame.Stride+x] = 1
+			}
+		}
+	}
+	a.frames = append(a.frames, frame)
+	a.turn = turn
+}
+
+// scaled returns a frame with every cell drawn scale pixels wide and high.
+func (a *animation) scaled(frame *image.Paletted) *image.Paletted {
+	if a.scale == 1 {
+		return frame
+	}
+	bounds := frame.Bounds()
+	scaled := image.NewPaletted(image.Rect(0, 0, bounds.Dx()*a.scale, bounds.Dy()*a.scale), a.palette)
+	for y := 0; y < bounds.Dy(); y++ {
+		row := scaled.Pix[y*a.scale*scaled.Stride : (y*a.scale+1)*scaled.Stride]
+		for x, cell := range frame.Pix[y*frame.Stride : y*frame.Stride+bounds.Dx()] {
+			for i := 0; i < a.scale; i++ {
+				row[x*a.scale+i] = cell
+			}
+		}
+		for i := 1; i < a.scale; i++ {
+			copy(scaled.Pix[(y*a.scale+i)*scaled.Stride:], row)
+		}
+	}
+	return scaled
+}
+
+// writeAnimation writes the frames of an animation to a file, as an APNG when the filename ends with .png and as a GIF otherwise.
+func writeAnimation(filename string, a *animation) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if strings.EqualFold(filepath.Ext(filename), ".png") {
+		err = writeAPNG(w, a)
+	} else {
+		err = writeGIF(w, a)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeGIF writes the frames as an animated GIF. Each frame is scaled up and encoded as a GIF on its own,
+// so that only one scaled frame is held at a time, then its delay and image are moved in after the header of the first one.
+// Without a global colour table, the header that is left out of the other frames is always the same length.
+func writeGIF(w io.Writer, a *animation) error {
+	for i, frame := range a.frames {
+		var encoded bytes.Buffer
+		err := gif.EncodeAll(&encoded, &gif.GIF{Image: []*image.Paletted{a.scaled(frame)}, Delay: []int{animationDelay}})
+		if err != nil {
+			return err
+		}
+		data := encoded.Bytes()
+		if i == 0 {
+			header := append(data[:gifHeaderLength:gifHeaderLength], gifLoopForever...)
+			if _, err := w.Write(header); err != nil {
+				return err
+			}
+		}
+		// Leave out the trailer, as more frames follow.
+		if _, err := w.Write(data[gifHeaderLength : len(data)-1]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{gifTrailer})
+	return err
+}
+
+const (
+	// gifHeaderLength is the length of the signature and logical screen descriptor of a GIF without a global colour table.
+	gifHeaderLength = 13
+	// gifLoopForever is the application extension that plays an animated GIF over and over again.
+	gifLoopForever = "\x21\xff\x0bNETSCAPE2.0\x03\x01\x00\x00\x00"
+	gifTrailer     = 0x3b
+)
+
+// writeAPNG writes the frames as an animated PNG. Each frame is encoded as a PNG on its own,
+// then its image data is moved into the frame data chunks that follow a frame control chunk.
+func writeAPNG(w io.Writer, a *animation) error {
+	var header, palette []byte
+	sequence := uint32(0)
+	var body bytes.Buffer
+	for i, frame := range a.frames {
+		frame = a.scaled(frame)
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, frame); err != nil {
+			return err
+		}
+		chunks, err := pngChunks(encoded.Bytes())
+		if err != nil {
+			return err
+		}
+
+		bounds := frame.Bounds()
+		control := make([]byte, 26)
+		binary.BigEndian.PutUint32(control[0:], sequence)
+		binary.BigEndian.PutUint32(control[4:], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(control[8:], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(control[20:], animationDelay)
+		binary.BigEndian.PutUint16(control[22:], 100)
+		writePngChunk(&body, "fcTL", control)
+		sequence++
+
+		for _, chunk := range chunks {
+			switch {
+			case chunk.kind == "IHDR" && i == 0:
+				header = chunk.data
+			case chunk.kind == "PLTE" && i == 0:
+				palette = chunk.data
+			case chunk.kind == "IDAT" && i == 0:
+				writePngChunk(&body, "IDAT", chunk.data)
+			case chunk.kind == "IDAT":
+				data := make([]byte, 4, 4+len(chunk.data))
+				binary.BigEndian.PutUint32(data, sequence)
+				writePngChunk(&body, "fdAT", append(data, chunk.data...))
+				sequence++
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(pngSignature)
+	writePngChunk(&out, "IHDR", header)
+	control := make([]byte, 8)
+	binary.BigEndian.PutUint32(control, uint32(len(a.frames)))
+	writePngChunk(&out, "acTL", control)
+	if palette != nil {
+		writePngChunk(&out, "PLTE", palette)
+	}
+	out.Write(body.Bytes())
+	writePngChunk(&out, "IEND", nil)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// pngChunks splits an encoded PNG into its chunks.
+func pngChunks(encoded []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(encoded, []byte(pngSignature)) {
+		return nil, errors.New("not a PNG")
+	}
+	var chunks []pngChunk
+	for rest := encoded[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, errors.New("PNG chunk is cut short")
+		}
+		length := int(binary.BigEndian.Uint32(rest))
+		if len(rest) < 12+length {
+			return nil, errors.New("PNG chunk is cut short")
+		}
+		chunks = append(chunks, pngChunk{string(rest[4:8]), rest[8 : 8+length]})
+		rest = rest[12+length:]
+	}
+	return chunks, nil
+}
+
+func writePngChunk(w *bytes.Buffer, kind string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	w.WriteString(kind)
+	w.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
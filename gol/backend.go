@@ -0,0 +1,54 @@
+package gol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Backend selects how the turns are processed on this machine.
+// It has no effect when the turns are processed on a broker.
+type Backend int
+
+const (
+	// Strips keeps the world as one byte per cell, split into strips that exchange halo rows.
+	Strips Backend = iota
+	// Bitboard packs 64 cells into every word and works out whole words of cells at once.
+	Bitboard
+	// HashLife moves the world on by whole powers of two turns at once with a memoized quadtree.
+	// It only supports the torus topology and is fastest on periodic or sparse worlds.
+	HashLife
+)
+
+var backendNames = map[Backend]string{
+	Strips:   "strips",
+	Bitboard: "bitboard",
+	HashLife: "hashlife",
+}
+
+// ParseBackend parses one of strips, bitboard or hashlife.
+func ParseBackend(name string) (Backend, error) {
+	for backend, backendName := range backendNames {
+		if strings.EqualFold(name, backendName) {
+			return backend, nil
+		}
+	}
+	return Strips, errors.New("unknown backend " + strconv.Quote(name) + ", should be one of strips, bitboard or hashlife")
+}
+
+func (b Backend) String() string {
+	if name, ok := backendNames[b]; ok {
+		return name
+	}
+	return "Incorrect Backend"
+}
+
+// Set allows a Backend to be used as a command line flag.
+func (b *Backend) Set(name string) error {
+	backend, err := ParseBackend(name)
+	if err != nil {
+		return err
+	}
+	*b = backend
+	return nil
+}
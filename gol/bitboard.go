@@ -0,0 +1,226 @@
+package gol
+
+import (
+	"math/bits"
+)
+
+// bitboard is the engine that packs every row of the world into words of 64 cells.
+// Cell x of a row is bit x%64 of word x/64, and the bits after the last cell of a row are always 0.
+// The next state of a whole word is worked out at once by adding up the eight neighbouring words bit by bit.
+type bitboard struct {
+	p     Params
+	c     distributorChannels
+	words int
+	wrap  bool
+	cells [][]uint64
+	next  [][]uint64
+
+	// above and below are the rows seen across the top and bottom edges of the board during the current turn.
+	above, below []uint64
+
+	// counts lists the neighbour counts that make a cell alive in the next turn.
+	counts []ruleCount
+
+	workers []chan int
+	done    chan bool
+}
+
+type ruleCount struct {
+	n       uint
+	birth   bool
+	survive bool
+}
+
+func startBitboard(p Params, c distributorChannels, world [][]uint8) *bitboard {
+	b := &bitboard{
+		p:     p,
+		c:     c,
+		words: (p.ImageWidth + 63) / 64,
+		wrap:  p.Topology.wrapsHorizontally(),
+	}
+	b.cells = makeBitMatrix(p.ImageHeight, b.words)
+	b.next = makeBitMatrix(p.ImageHeight, b.words)
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 255 {
+				b.cells[y][x/64] |= 1 << uint(x%64)
+			}
+		}
+	}
+
+	for n := uint(0); n <= 8; n++ {
+		count := ruleCount{n: n, birth: p.Rule.Birth&(1<<n) != 0, survive: p.Rule.Survive&(1<<n) != 0}
+		if count.birth || count.survive {
+			b.counts = append(b.counts, count)
+		}
+	}
+
+	numberOfWorkers := getNumberOfWorkers(p, p.Threads)
+	b.done = make(chan bool, numberOfWorkers)
+	for j := 0; j < numberOfWorkers; j++ {
+		startY, endY := getWorkerBounds(p.ImageHeight, j, numberOfWorkers)
+		turns := make(chan int)
+		b.workers = append(b.workers, turns)
+		go b.worker(startY, endY, turns)
+	}
+	return b
+}
+
+func makeBitMatrix(height, words int) [][]uint64 {
+	matrix := make([][]uint64, height)
+	for i := range matrix {
+		matrix[i] = make([]uint64, words)
+	}
+	return matrix
+}
+
+func (b *bitboard) worker(startY, endY int, turns <-chan int) {
+	for turn := range turns {
+		flips := newFlips(b.p, b.c.events, turn)
+		for y := startY; y < endY; y++ {
+			b.nextRow(y, flips)
+		}
+		flips.send()
+		b.done <- true
+	}
+}
+
+func (b *bitboard) nextTurn(turn int) error {
+	height := b.p.ImageHeight
+	b.above = b.acrossEdge(b.cells[height-1])
+	b.below = b.acrossEdge(b.cells[0])
+	for _, turns := range b.workers {
+		turns <- turn
+	}
+	for range b.workers {
+		<-b.done
+	}
+	b.cells, b.next = b.next, b.cells
+	return nil
+}
+
+// acrossEdge returns the packed halo row seen across the top or bottom edge of the board.
+func (b *bitboard) acrossEdge(row []uint64) []uint64 {
+	halo := make([]uint64, b.words)
+	switch b.p.Topology {
+	case Torus:
+		copy(halo, row)
+	case KleinBottle:
+		width := b.p.ImageWidth
+		for x := 0; x < width; x++ {
+			mirrored := width - 1 - x
+			halo[x/64] |= (row[mirrored/64] >> uint(mirrored%64) & 1) << uint(x%64)
+		}
+	}
+	return halo
+}
+
+// nextRow works out row y of the next turn, adding every cell that changed to flips.
+func (b *bitboard) nextRow(y int, flips *flips) {
+	up, down := b.above, b.below
+	if y > 0 {
+		up = b.cells[y-1]
+	}
+	if y < b.p.ImageHeight-1 {
+		down = b.cells[y+1]
+	}
+	row := b.cells[y]
+	newRow := b.next[y]
+
+	for i := 0; i < b.words; i++ {
+		newRow[i] = b.nextWord(row[i], [8]uint64{
+			b.leftNeighbours(up, i), up[i], b.rightNeighbours(up, i),
+			b.leftNeighbours(row, i), b.rightNeighbours(row, i),
+			b.leftNeighbours(down, i), down[i], b.rightNeighbours(down, i),
+		})
+		if i == b.words-1 && b.p.ImageWidth%64 != 0 {
+			newRow[i] &= 1<<uint(b.p.ImageWidth%64) - 1
+		}
+
+		if flips == nil {
+			continue
+		}
+		for changed := row[i] ^ newRow[i]; changed != 0; changed &= changed - 1 {
+			x := i*64 + bits.TrailingZeros64(changed)
+			flips.flip(x, y)
+		}
+	}
+}
+
+// leftNeighbours returns word i of row moved one cell to the right, so that every bit holds the cell to its left.
+func (b *bitboard) leftNeighbours(row []uint64, i int) uint64 {
+	word := row[i] << 1
+	if i > 0 {
+		word |= row[i-1] >> 63
+	} else if b.wrap {
+		last := uint(b.p.ImageWidth - 1)
+		word |= row[last/64] >> (last % 64) & 1
+	}
+	return word
+}
+
+// rightNeighbours returns word i of row moved one cell to the left, so that every bit holds the cell to its right.
+func (b *bitboard) rightNeighbours(row []uint64, i int) uint64 {
+	word := row[i] >> 1
+	if i < b.words-1 {
+		word |= row[i+1] << 63
+	} else if b.wrap {
+		word |= (row[0] & 1) << uint((b.p.ImageWidth-1)%64)
+	}
+	return word
+}
+
+// nextWord adds up the neighbours of 64 cells at once with carry-save adders and applies the rule to them.
+func (b *bitboard) nextWord(current uint64, neighbours [8]uint64) uint64 {
+	sumA, carryA := fullAdder(neighbours[0], neighbours[1], neighbours[2])
+	sumB, carryB := fullAdder(neighbours[3], neighbours[4], neighbours[5])
+	sumC, carryC := neighbours[6]^neighbours[7], neighbours[6]&neighbours[7]
+
+	ones, carryD := fullAdder(sumA, sumB, sumC)
+	sumE, fourA := fullAdder(carryA, carryB, carryC)
+	twos, fourB := sumE^carryD, sumE&carryD
+	fours, eights := fourA^fourB, fourA&fourB
+
+	var next uint64
+	for _, count := range b.counts {
+		matches := ^uint64(0)
+		for bit, sum := range [4]uint64{ones, twos, fours, eights} {
+			if count.n&(1<<uint(bit)) != 0 {
+				matches &= sum
+			} else {
+				matches &^= sum
+			}
+		}
+		switch {
+		case count.birth && count.survive:
+			next |= matches
+		case count.birth:
+			next |= matches &^ current
+		default:
+			next |= matches & current
+		}
+	}
+	return next
+}
+
+func fullAdder(a, b, c uint64) (sum, carry uint64) {
+	return a ^ b ^ c, a&b | a&c | b&c
+}
+
+func (b *bitboard) world() ([][]uint8, error) {
+	world := makeMatrix(b.p.ImageHeight, b.p.ImageWidth)
+	for y, row := range b.cells {
+		for x := range world[y] {
+			if row[x/64]>>uint(x%64)&1 != 0 {
+				world[y][x] = 255
+			}
+		}
+	}
+	return world, nil
+}
+
+func (b *bitboard) close() {
+	for _, turns := range b.workers {
+		close(turns)
+	}
+}
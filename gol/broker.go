@@ -0,0 +1,585 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Broker receives the world from a local controller and splits it into strips kept by the worker servers.
+// Every turn it swaps the edge rows of neighbouring strips and collects the cells that changed.
+// The turns are processed when the controller asks for them, or in the background once the controller has detached.
+// A worker that fails is dropped, and the last snapshot of the world is split between the workers that are left.
+type Broker struct {
+	mu        sync.Mutex
+	workers   []*rpc.Client
+	addresses []string
+	active    int
+	params    Params
+	edges     []StepResponse
+	turn      int
+
+	// board is a snapshot of the world after boardTurn turns, which is put back together from the strips every SnapshotEvery turns.
+	// When a worker fails, the strips are split from it again and the turns since it are processed again.
+	board     [][]uint8
+	boardTurn int
+	// lost lists the addresses of the workers that have failed since the controller was last told.
+	lost []string
+
+	// Timeout is how long a worker has to reply before it is treated as failed. There is no limit when it is 0.
+	Timeout time.Duration
+
+	// SnapshotEvery is how many turns are processed between snapshots of the world.
+	// Fewer turns have to be processed again when a worker fails, but the whole world is sent by the workers more often.
+	// A snapshot is only taken when the world is asked for when it is 0.
+	SnapshotEvery int
+
+	// AttachTimeout is how long a controller that attached has to ask for a turn or the world before it loses the run,
+	// which is then carried on in the background as if it had detached again. There is no limit when it is 0.
+	AttachTimeout time.Duration
+
+	// detached is closed to stop the turns processed in the background, and stopped is closed once they have stopped.
+	detached, stopped chan struct{}
+	// detachedErr is why the turns processed in the background stopped early.
+	detachedErr error
+
+	// leaseUntil is when the controller that attached loses the run, unless it asks for a turn or the world before then.
+	// It is zero when no controller has attached. lease counts the leases given out, so that a timer for an old one does nothing.
+	leaseUntil time.Time
+	lease      int
+
+	done     chan struct{}
+	stopping sync.Once
+}
+
+// DefaultWorkerTimeout is how long a new broker waits for a worker to reply before treating it as failed.
+const DefaultWorkerTimeout = 10 * time.Second
+
+// DefaultSnapshotEvery is how many turns a new broker processes between snapshots of the world.
+const DefaultSnapshotEvery = 100
+
+// DefaultAttachTimeout is how long a new broker waits to hear from a controller that attached before carrying on without it.
+const DefaultAttachTimeout = time.Minute
+
+// NewBroker connects to the worker servers listening on the given addresses.
+func NewBroker(addresses []string) (*Broker, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("broker needs at least one worker")
+	}
+	b := &Broker{
+		Timeout:       DefaultWorkerTimeout,
+		SnapshotEvery: DefaultSnapshotEvery,
+		AttachTimeout: DefaultAttachTimeout,
+		done:          make(chan struct{}),
+	}
+	for _, address := range addresses {
+		client, err := rpc.Dial("tcp", address)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.workers = append(b.workers, client)
+		b.addresses = append(b.addresses, address)
+	}
+	return b, nil
+}
+
+// Close disconnects the broker from all of its workers.
+func (b *Broker) Close() {
+	for _, client := range b.workers {
+		_ = client.Close()
+	}
+}
+
+// Start splits the world between the workers, replacing any run that was already on the broker.
+func (b *Broker) Start(req StartRequest, res *StartResponse) error {
+	b.stopDetached()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.endLease()
+	b.params = req.Params
+	b.turn = req.CompletedTurns
+	b.board, b.boardTurn = req.World, req.CompletedTurns
+	b.detachedErr = nil
+	return b.split()
+}
+
+// split hands every active worker its strip of the board.
+// Workers that fail are dropped and the board is split again between the rest. The lock must be held.
+func (b *Broker) split() error {
+	for {
+		if len(b.workers) == 0 {
+			return errors.New("broker has no workers left")
+		}
+		b.active = getNumberOfWorkers(b.params, len(b.workers))
+		b.edges = make([]StepResponse, b.active)
+
+		calls := make([]*rpc.Call, b.active)
+		for j := 0; j < b.active; j++ {
+			startY, endY := getWorkerBounds(b.params.ImageHeight, j, b.active)
+			initRequest := InitRequest{Params: b.params, StartY: startY, Rows: b.board[startY:endY]}
+			calls[j] = b.workers[j].Go(WorkerInit, initRequest, new(InitResponse), nil)
+			b.edges[j] = StepResponse{Top: copyRow(b.board[startY]), Bottom: copyRow(b.board[endY-1])}
+		}
+		failed := b.waitForWorkers(calls)
+		if len(failed) == 0 {
+			return nil
+		}
+		b.drop(failed)
+	}
+}
+
+// NextTurn hands every worker the edge rows of its neighbours and collects the cells that changed.
+func (b *Broker) NextTurn(req TurnRequest, res *TurnResponse) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.edges == nil {
+		return errors.New("broker has not been started")
+	}
+	if b.detached != nil {
+		return errors.New("broker is processing turns for a detached controller")
+	}
+	b.renewLease()
+
+	flipped, err := b.step(req.SkipFlipped)
+	if err != nil {
+		return err
+	}
+	res.Flipped = flipped
+	res.CompletedTurns = b.turn
+	res.Lost = b.lost
+	res.Workers = len(b.workers)
+	b.lost = nil
+	return nil
+}
+
+// step processes one turn on the workers, returning the cells that changed unless skipFlipped is set. The lock must be held.
+// When a worker fails, the workers that are left catch up from the last snapshot and the turn is processed again.
+func (b *Broker) step(skipFlipped bool) ([]util.Cell, error) {
+	for {
+		calls, failed := b.stepWorkers(skipFlipped)
+		if len(failed) > 0 {
+			b.drop(failed)
+			if err := b.recover(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var flipped []util.Cell
+		for j, call := range calls {
+			b.edges[j] = *call.Reply.(*StepResponse)
+			flipped = append(flipped, b.edges[j].Flipped...)
+			b.edges[j].Flipped = nil
+		}
+		b.turn++
+		if b.SnapshotEvery > 0 && b.turn-b.boardTurn >= b.SnapshotEvery {
+			if err := b.snapshot(); err != nil {
+				return nil, err
+			}
+		}
+		return flipped, nil
+	}
+}
+
+// stepWorkers asks every active worker to process one turn, returning the calls and the workers that failed.
+// The workers only keep track of the cells that change when skipFlipped is not set. The edges are left as they were. The lock must be held.
+func (b *Broker) stepWorkers(skipFlipped bool) ([]*rpc.Call, map[int]bool) {
+	n := b.active
+	calls := make([]*rpc.Call, n)
+	for j := 0; j < n; j++ {
+		stepRequest := StepRequest{Above: b.edges[(j-1+n)%n].Bottom, Below: b.edges[(j+1)%n].Top, SkipFlipped: skipFlipped}
+		if j == 0 {
+			stepRequest.Above = b.params.Topology.acrossEdge(stepRequest.Above)
+		}
+		if j == n-1 {
+			stepRequest.Below = b.params.Topology.acrossEdge(stepRequest.Below)
+		}
+		calls[j] = b.workers[j].Go(WorkerStep, stepRequest, new(StepResponse), nil)
+	}
+	return calls, b.waitForWorkers(calls)
+}
+
+// recover splits the last snapshot between the workers that are left, and has them process the turns since it again.
+// The lock must be held.
+func (b *Broker) recover() error {
+	turn := b.turn
+	for {
+		b.turn = b.boardTurn
+		if err := b.split(); err != nil {
+			return err
+		}
+		var failed map[int]bool
+		for b.turn < turn && len(failed) == 0 {
+			var calls []*rpc.Call
+			calls, failed = b.stepWorkers(true)
+			if len(failed) == 0 {
+				for j, call := range calls {
+					b.edges[j] = *call.Reply.(*StepResponse)
+					b.edges[j].Flipped = nil
+				}
+				b.turn++
+			}
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		b.drop(failed)
+	}
+}
+
+// snapshot puts the world back together from the strips of the workers and keeps it as the board. The lock must be held.
+func (b *Broker) snapshot() error {
+	for {
+		calls := make([]*rpc.Call, b.active)
+		for j := range calls {
+			calls[j] = b.workers[j].Go(WorkerRows, RowsRequest{}, new(RowsResponse), nil)
+		}
+		failed := b.waitForWorkers(calls)
+		if len(failed) == 0 {
+			board := make([][]uint8, 0, b.params.ImageHeight)
+			for _, call := range calls {
+				board = append(board, call.Reply.(*RowsResponse).Rows...)
+			}
+			b.board, b.boardTurn = board, b.turn
+			return nil
+		}
+		b.drop(failed)
+		if err := b.recover(); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForWorkers waits for a call to each active worker, returning the workers that failed or did not reply in time.
+func (b *Broker) waitForWorkers(calls []*rpc.Call) map[int]bool {
+	var timeout <-chan time.Time
+	if b.Timeout > 0 {
+		timer := time.NewTimer(b.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	failed := make(map[int]bool)
+	expired := false
+	for j, call := range calls {
+		done := false
+		if expired {
+			// Once the time is up, only the workers that have already replied are waited for.
+			select {
+			case <-call.Done:
+				done = true
+			default:
+			}
+		} else {
+			select {
+			case <-call.Done:
+				done = true
+			case <-timeout:
+				expired = true
+			}
+		}
+		if !done || call.Error != nil {
+			failed[j] = true
+		}
+	}
+	return failed
+}
+
+// drop disconnects from the failed workers and remembers them, so that the controller can be told. The lock must be held.
+func (b *Broker) drop(failed map[int]bool) {
+	var workers []*rpc.Client
+	var addresses []string
+	for j, client := range b.workers {
+		if failed[j] {
+			_ = client.Close()
+			b.lost = append(b.lost, b.addresses[j])
+			continue
+		}
+		workers = append(workers, client)
+		addresses = append(addresses, b.addresses[j])
+	}
+	b.workers = workers
+	b.addresses = addresses
+}
+
+// World returns the world after the last turn.
+func (b *Broker) World(req WorldRequest, res *WorldResponse) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.edges == nil {
+		return errors.New("broker has not been started")
+	}
+	b.renewLease()
+	if err := b.snapshot(); err != nil {
+		return err
+	}
+
+	res.World = b.world()
+	res.CompletedTurns = b.turn
+	return nil
+}
+
+// world returns a copy of the last snapshot, which can be sent once the lock is released. The lock must be held.
+func (b *Broker) world() [][]uint8 {
+	world := make([][]uint8, len(b.board))
+	for y, row := range b.board {
+		world[y] = copyRow(row)
+	}
+	return world
+}
+
+func copyRow(row []uint8) []uint8 {
+	return append([]uint8(nil), row...)
+}
+
+// Detach lets the controller disconnect while the broker carries on processing turns in the background,
+// until another controller attaches or all of the turns have been processed.
+func (b *Broker) Detach(req DetachRequest, res *DetachResponse) error {
+	b.stopDetached()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.edges == nil {
+		return errors.New("broker has not been started")
+	}
+
+	b.endLease()
+	b.detach()
+	res.CompletedTurns = b.turn
+	return nil
+}
+
+// detach starts processing the turns in the background. The lock must be held.
+func (b *Broker) detach() {
+	b.detached = make(chan struct{})
+	b.stopped = make(chan struct{})
+	go b.runDetached(b.detached, b.stopped)
+}
+
+// Attach stops the turns processed in the background and hands back the run as it is,
+// so that a new controller can carry on with it. Only a run that a controller has detached from can be attached to.
+// The new controller holds the run for as long as it keeps asking for turns or the world within AttachTimeout,
+// so that a controller that dies after attaching does not leave the run stuck.
+func (b *Broker) Attach(req AttachRequest, res *AttachResponse) error {
+	if !b.stopDetached() {
+		return errors.New("broker has no detached run to attach to")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.edges == nil {
+		return errors.New("broker has no run to attach to")
+	}
+	if b.detachedErr != nil {
+		return fmt.Errorf("run stopped at turn %v: %v", b.turn, b.detachedErr)
+	}
+
+	if err := b.snapshot(); err != nil {
+		return err
+	}
+
+	res.Params = b.params
+	res.CompletedTurns = b.turn
+	res.World = b.world()
+	b.startLease()
+	return nil
+}
+
+// startLease gives the run to the controller that attached for AttachTimeout. The lock must be held.
+func (b *Broker) startLease() {
+	b.lease++
+	if b.AttachTimeout <= 0 {
+		return
+	}
+	b.leaseUntil = time.Now().Add(b.AttachTimeout)
+	lease := b.lease
+	time.AfterFunc(b.AttachTimeout, func() {
+		b.expireLease(lease)
+	})
+}
+
+// renewLease gives the controller that attached another AttachTimeout, if it has a lease. The lock must be held.
+func (b *Broker) renewLease() {
+	if !b.leaseUntil.IsZero() {
+		b.leaseUntil = time.Now().Add(b.AttachTimeout)
+	}
+}
+
+// endLease forgets the controller that attached, so that its lease never runs out. The lock must be held.
+func (b *Broker) endLease() {
+	b.lease++
+	b.leaseUntil = time.Time{}
+}
+
+// expireLease carries on with the run in the background once the lease has run out without being renewed,
+// so that it can be attached to again. The timer is set again for the rest of the lease when it has been renewed.
+func (b *Broker) expireLease(lease int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lease != b.lease || b.leaseUntil.IsZero() {
+		return
+	}
+	if left := time.Until(b.leaseUntil); left > 0 {
+		time.AfterFunc(left, func() {
+			b.expireLease(lease)
+		})
+		return
+	}
+	b.endLease()
+	b.detach()
+}
+
+// runDetached processes turns until detached is closed or the turns are finished.
+func (b *Broker) runDetached(detached <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	for {
+		select {
+		case <-detached:
+			return
+		default:
+		}
+
+		b.mu.Lock()
+		finished := b.turn >= b.params.Turns
+		if !finished {
+			_, b.detachedErr = b.step(true)
+		}
+		err := b.detachedErr
+		b.mu.Unlock()
+		if finished || err != nil {
+			return
+		}
+	}
+}
+
+// stopDetached stops the turns processed in the background, if there are any, and waits for them to stop.
+// It reports whether the run had been detached from.
+func (b *Broker) stopDetached() bool {
+	b.mu.Lock()
+	detached, stopped := b.detached, b.stopped
+	b.detached, b.stopped = nil, nil
+	b.mu.Unlock()
+	if detached == nil {
+		return false
+	}
+	close(detached)
+	<-stopped
+	return true
+}
+
+// Shutdown tells every worker server to exit, then closes Done so that the broker process can exit too.
+func (b *Broker) Shutdown(req ShutdownRequest, res *ShutdownResponse) error {
+	b.stopDetached()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.endLease()
+
+	calls := make([]*rpc.Call, len(b.workers))
+	for j, worker := range b.workers {
+		calls[j] = worker.Go(WorkerShutdown, ShutdownRequest{}, new(ShutdownResponse), nil)
+	}
+	err := waitForCalls(calls)
+	b.stopping.Do(func() {
+		close(b.done)
+	})
+	return ignoreShutdownError(err)
+}
+
+// Done is closed once the broker has been shut down.
+func (b *Broker) Done() <-chan struct{} {
+	return b.done
+}
+
+// ignoreShutdownError ignores the error from a process that exited before replying to a shutdown.
+func ignoreShutdownError(err error) error {
+	if err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
+
+// waitForCalls waits for all the calls to finish and returns the first error any of them had.
+func waitForCalls(calls []*rpc.Call) error {
+	var err error
+	for _, call := range calls {
+		<-call.Done
+		if call.Error != nil && err == nil {
+			err = call.Error
+		}
+	}
+	return err
+}
+
+// WorkerServer keeps one strip of the world and processes it a turn at a time for the broker.
+type WorkerServer struct {
+	mu    sync.Mutex
+	strip *strip
+
+	done     chan struct{}
+	stopping sync.Once
+}
+
+func (s *WorkerServer) Init(req InitRequest, res *InitResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strip = newStrip(req.Params, req.StartY, req.Rows)
+	return nil
+}
+
+func (s *WorkerServer) Step(req StepRequest, res *StepResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.strip == nil {
+		return errors.New("worker has no strip")
+	}
+	s.strip.setHalos(req.Above, req.Below)
+	var flip func(x, y int)
+	if !req.SkipFlipped {
+		flip = func(x, y int) {
+			res.Flipped = append(res.Flipped, util.Cell{X: x, Y: y})
+		}
+	}
+	s.strip.step(flip)
+	res.Top = s.strip.top()
+	res.Bottom = s.strip.bottom()
+	return nil
+}
+
+func (s *WorkerServer) Rows(req RowsRequest, res *RowsResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.strip == nil {
+		return errors.New("worker has no strip")
+	}
+	res.Rows = s.strip.rows()
+	return nil
+}
+
+// Shutdown closes Done so that the worker server process can exit.
+func (s *WorkerServer) Shutdown(req ShutdownRequest, res *ShutdownResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopping.Do(func() {
+		close(s.doneChannel())
+	})
+	return nil
+}
+
+// Done is closed once the worker server has been shut down.
+func (s *WorkerServer) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doneChannel()
+}
+
+// doneChannel makes the Done channel the first time it is needed, so that the zero WorkerServer is ready to use.
+func (s *WorkerServer) doneChannel() chan struct{} {
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	return s.done
+}
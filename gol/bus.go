@@ -0,0 +1,214 @@
+package gol
+
+import (
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// OverflowPolicy decides what happens when an event is sent to a subscriber whose buffer is full.
+type OverflowPolicy int
+
+const (
+	// Block waits until the subscriber has made room, which holds up the run.
+	Block OverflowPolicy = iota
+	// DropOldest throws away the oldest buffered event to make room.
+	DropOldest
+	// Coalesce merges the buffered events to make room. The cells of CellsFlipped events are put together,
+	// and only the last of the TurnComplete events between them is kept, so a window skips frames without losing any cells.
+	// Only the latest AliveCellsCount and StatsReport events are kept.
+	Coalesce
+)
+
+// Bus passes the events of a run to any number of subscribers, each with its own buffer and OverflowPolicy.
+// StateEvents are never thrown away, so a subscriber that stops reading holds up the run once its buffer is full of them.
+type Bus struct {
+	events      chan Event
+	mu          sync.Mutex
+	subscribers []*subscriber
+	closed      bool
+}
+
+// NewBus returns a bus that passes on the events sent to Events.
+func NewBus() *Bus {
+	b := &Bus{events: make(chan Event)}
+	go b.run()
+	return b
+}
+
+// Events returns the channel to give to Run. The subscribers' channels are closed once it is closed.
+func (b *Bus) Events() chan<- Event {
+	return b.events
+}
+
+// Subscribe returns a channel that is sent every event from now on, with room for buffer events that have not been read yet.
+// It is closed straight away when the events channel has already been closed.
+func (b *Bus) Subscribe(buffer int, policy OverflowPolicy) <-chan Event {
+	if buffer < 1 {
+		buffer = 1
+	}
+	s := &subscriber{size: buffer, policy: policy, out: make(chan Event)}
+	s.ready = sync.NewCond(&s.mu)
+	go s.deliver()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.close()
+	} else {
+		b.subscribers = append(b.subscribers, s)
+	}
+	return s.out
+}
+
+func (b *Bus) run() {
+	for event := range b.events {
+		b.mu.Lock()
+		subscribers := b.subscribers
+		b.mu.Unlock()
+		for _, s := range subscribers {
+			s.publish(event)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, s := range b.subscribers {
+		s.close()
+	}
+}
+
+// subscriber buffers the events for one subscriber, which deliver sends on in order.
+type subscriber struct {
+	size   int
+	policy OverflowPolicy
+	out    chan Event
+
+	mu     sync.Mutex
+	ready  *sync.Cond
+	queue  []Event
+	closed bool
+}
+
+func (s *subscriber) publish(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) >= s.size {
+		switch {
+		case s.policy == DropOldest && s.dropOldest():
+		case s.policy == Coalesce && s.coalesce():
+		default:
+			s.ready.Wait()
+		}
+	}
+	s.queue = append(s.queue, event)
+	s.ready.Broadcast()
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.ready.Broadcast()
+}
+
+func (s *subscriber) deliver() {
+	s.mu.Lock()
+	for {
+		for len(s.queue) == 0 && !s.closed {
+			s.ready.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			close(s.out)
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.ready.Broadcast()
+		s.mu.Unlock()
+		s.out <- event
+		s.mu.Lock()
+	}
+}
+
+// dropOldest throws away the oldest buffered event that is not one of the StateEvents, returning false when there are none.
+func (s *subscriber) dropOldest() bool {
+	for i, event := range s.queue {
+		if kindOf(event) != StateEvents {
+			s.queue = append(s.queue[:i:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// coalesce merges the buffered events, returning false when that made no room.
+func (s *subscriber) coalesce() bool {
+	var merged []Event
+	for _, event := range s.queue {
+		n := len(merged)
+		switch event := event.(type) {
+		case CellsFlipped:
+			if n > 0 {
+				if flips, ok := merged[n-1].(CellsFlipped); ok {
+					merged[n-1] = mergeFlips(flips, event)
+					continue
+				}
+			}
+			if n > 1 {
+				_, turnComplete := merged[n-1].(TurnComplete)
+				if flips, ok := merged[n-2].(CellsFlipped); ok && turnComplete {
+					merged[n-2] = mergeFlips(flips, event)
+					continue
+				}
+			}
+		case TurnComplete:
+			if n > 0 {
+				if _, ok := merged[n-1].(TurnComplete); ok {
+					merged[n-1] = event
+					continue
+				}
+			}
+		case AliveCellsCount:
+			merged = removeEvents(merged, func(e Event) bool { _, ok := e.(AliveCellsCount); return ok })
+		case StatsReport:
+			merged = removeEvents(merged, func(e Event) bool { _, ok := e.(StatsReport); return ok })
+		}
+		merged = append(merged, event)
+	}
+	shorter := len(merged) < len(s.queue)
+	s.queue = merged
+	return shorter
+}
+
+// mergeFlips puts the cells of two CellsFlipped events together. A cell in both is flipped twice, as it would have been.
+func mergeFlips(first, second CellsFlipped) CellsFlipped {
+	cells := make([]util.Cell, 0, len(first.Cells)+len(second.Cells))
+	cells = append(append(cells, first.Cells...), second.Cells...)
+	return CellsFlipped{CompletedTurns: second.CompletedTurns, Cells: cells}
+}
+
+func removeEvents(events []Event, remove func(Event) bool) []Event {
+	kept := events[:0]
+	for _, event := range events {
+		if !remove(event) {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// kindOf returns the kind of an event.
+func kindOf(event Event) EventKind {
+	switch event.(type) {
+	case CellsFlipped, CellFlipped:
+		return FlipEvents
+	case TurnComplete:
+		return TurnEvents
+	case AliveCellsCount, StatsReport:
+		return ReportEvents
+	}
+	return StateEvents
+}
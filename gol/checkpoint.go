@@ -0,0 +1,95 @@
+package gol
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checkpointHeader starts every checkpoint file, followed by a gzipped gob of a checkpoint.
+const checkpointHeader = "GOL CHECKPOINT 1\n"
+
+// checkpoint is everything needed to carry on a run from the turn it stopped at.
+type checkpoint struct {
+	Params         Params
+	CompletedTurns int
+	World          [][]uint8
+}
+
+// resume returns p with the details of the world taken from the checkpoint, and its number of turns when p has none.
+// How the turns are processed, such as the number of threads or the broker, is still taken from p.
+func (cp checkpoint) resume(p Params) Params {
+	if p.Turns == 0 {
+		p.Turns = cp.Params.Turns
+	}
+	p.ImageWidth = cp.Params.ImageWidth
+	p.ImageHeight = cp.Params.ImageHeight
+	p.Rule, p.RuleGiven = cp.Params.Rule, true
+	p.Topology = cp.Params.Topology
+	return p
+}
+
+func readCheckpoint(filename string) (checkpoint, error) {
+	var cp checkpoint
+	file, err := os.Open(filename)
+	if err != nil {
+		return cp, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, len(checkpointHeader))
+	if _, err = io.ReadFull(reader, header); err != nil || string(header) != checkpointHeader {
+		return cp, errors.New(filename + " is not a checkpoint")
+	}
+	decompressor, err := gzip.NewReader(reader)
+	if err != nil {
+		return cp, err
+	}
+	if err = gob.NewDecoder(decompressor).Decode(&cp); err != nil {
+		return cp, err
+	}
+	if len(cp.World) != cp.Params.ImageHeight {
+		return cp, errors.New(filename + " has the wrong number of rows")
+	}
+	for _, row := range cp.World {
+		if len(row) != cp.Params.ImageWidth {
+			return cp, errors.New(filename + " has the wrong number of columns")
+		}
+	}
+	return cp, nil
+}
+
+// writeCheckpoint writes to a temporary file first and then renames it,
+// so that a run stopped halfway through writing still leaves the previous checkpoint intact.
+func writeCheckpoint(filename string, cp checkpoint) error {
+	temporary := filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	file, err := os.Create(temporary)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(checkpointHeader)
+	if err == nil {
+		compressor := gzip.NewWriter(file)
+		err = gob.NewEncoder(compressor).Encode(cp)
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(temporary)
+		return err
+	}
+	return os.Rename(temporary, filename)
+}
@@ -0,0 +1,216 @@
+package gol
+
+import (
+	"sort"
+	"time"
+)
+
+// Command controls a run between turns. Commands are sent to RunWithCommands,
+// and each one can carry a Reply channel that is sent an Ack once the command has been applied.
+// The Reply channel may be nil, and otherwise needs room for the Ack or someone waiting for it.
+type Command interface {
+	reply() chan<- Ack
+	withReply(reply chan<- Ack) Command
+}
+
+// Ack acknowledges that a command has been applied, after the given number of turns.
+// Err is set when a Save, Quit, Shutdown or Animate could not write its files, or the broker could not be shut down or detached from.
+type Ack struct {
+	CompletedTurns int
+	Err            error
+}
+
+// Save writes the current world to the output file.
+type Save struct {
+	Reply chan<- Ack
+}
+
+// Quit writes the current world and a checkpoint, then stops the run.
+type Quit struct {
+	Reply chan<- Ack
+}
+
+// Shutdown quits the run like Quit, and also tells the broker and its worker servers to exit when there is one.
+type Shutdown struct {
+	Reply chan<- Ack
+}
+
+// Detach stops the run on this controller and leaves the broker processing the rest of the turns,
+// so that another controller can attach to it later. Nothing is written out.
+// It is acknowledged with an error, and the run carries on, when the turns are not processed on a broker.
+type Detach struct {
+	Reply chan<- Ack
+}
+
+// Pause stops processing turns until Resume. It does nothing when the run is already paused.
+type Pause struct {
+	Reply chan<- Ack
+}
+
+// Resume carries on processing turns after Pause. It does nothing when the run is not paused.
+type Resume struct {
+	Reply chan<- Ack
+}
+
+// TogglePause pauses the run when it is processing turns, and resumes it when it is paused.
+type TogglePause struct {
+	Reply chan<- Ack
+}
+
+// Step processes the given number of turns, even while the run is paused, which stays paused afterwards.
+// Params.StepTurns turns are processed when Turns is 0. It is acknowledged once the turns have been processed.
+type Step struct {
+	Turns int
+	Reply chan<- Ack
+}
+
+// StepBack pauses the run and goes back to the board before the last turn that was stepped through while paused,
+// as long as it is still kept in the history. It is acknowledged with an error when there is no earlier board,
+// which is always the case just after pausing, as no boards are kept while the run is not paused.
+type StepBack struct {
+	Reply chan<- Ack
+}
+
+// SetSpeed limits how many turns are processed every second. There is no limit when it is 0.
+type SetSpeed struct {
+	TurnsPerSecond float64
+	Reply          chan<- Ack
+}
+
+// ChangeSpeed moves the limit on how many turns are processed every second the given number of steps faster,
+// or slower when Steps is negative, along 1, 2, 5, 10, 20, 50, 100, 200, 500 and 1000 turns per second.
+// The fastest step is no limit at all.
+type ChangeSpeed struct {
+	Steps int
+	Reply chan<- Ack
+}
+
+// speeds are the limits that ChangeSpeed moves between.
+var speeds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// changeSpeed returns the limit the given number of steps along speeds from speed, where 0 is no limit.
+func changeSpeed(speed float64, steps int) float64 {
+	i := len(speeds)
+	if speed > 0 {
+		i = sort.SearchFloat64s(speeds, speed)
+	}
+	if steps > 0 && i < len(speeds) && speeds[i] != speed {
+		// The speed is between two steps, so the one above it is the first step faster.
+		i--
+	}
+	i += steps
+	if i < 0 {
+		return speed
+	}
+	if i >= len(speeds) {
+		return 0
+	}
+	return speeds[i]
+}
+
+// pace schedules the turns of a run that is limited to a number of turns per second.
+// Each turn is due a fixed time after the one before it was due, rather than after it was processed,
+// so that the time taken by the turns themselves does not slow the run down and turns that are late are caught up on.
+type pace struct {
+	due time.Time
+}
+
+// after returns a channel that fires when the turn after the given number of turns at speed is due,
+// or nil when it is already due. A run that has fallen more than a second behind starts again from now,
+// rather than rushing through every turn it missed.
+func (pc *pace) after(turns int, speed float64) <-chan time.Time {
+	now := time.Now()
+	if pc.due.IsZero() || now.Sub(pc.due) > time.Second {
+		pc.due = now
+	}
+	pc.due = pc.due.Add(time.Duration(float64(turns) * float64(time.Second) / speed))
+	if !pc.due.After(now) {
+		return nil
+	}
+	return time.After(pc.due.Sub(now))
+}
+
+// reset starts the schedule again from the next turn, for when the speed changes or the run stops and starts.
+func (pc *pace) reset() {
+	pc.due = time.Time{}
+}
+
+// Animate starts an animation of the run from the current turn, with a frame every Params.AnimateEvery turns.
+// When an animation is already being made, it is finished and written out instead.
+// It is acknowledged with an error when the animation could not be written.
+type Animate struct {
+	Reply chan<- Ack
+}
+
+// snapshot asks for a copy of the world and the turns completed so far.
+type snapshot struct {
+	result chan<- Result
+}
+
+func (c Save) reply() chan<- Ack        { return c.Reply }
+func (c Quit) reply() chan<- Ack        { return c.Reply }
+func (c Shutdown) reply() chan<- Ack    { return c.Reply }
+func (c Detach) reply() chan<- Ack      { return c.Reply }
+func (c Pause) reply() chan<- Ack       { return c.Reply }
+func (c Resume) reply() chan<- Ack      { return c.Reply }
+func (c TogglePause) reply() chan<- Ack { return c.Reply }
+func (c Step) reply() chan<- Ack        { return c.Reply }
+func (c StepBack) reply() chan<- Ack    { return c.Reply }
+func (c SetSpeed) reply() chan<- Ack    { return c.Reply }
+func (c ChangeSpeed) reply() chan<- Ack { return c.Reply }
+func (c Animate) reply() chan<- Ack     { return c.Reply }
+func (c snapshot) reply() chan<- Ack    { return nil }
+
+func (c Save) withReply(reply chan<- Ack) Command        { c.Reply = reply; return c }
+func (c Quit) withReply(reply chan<- Ack) Command        { c.Reply = reply; return c }
+func (c Shutdown) withReply(reply chan<- Ack) Command    { c.Reply = reply; return c }
+func (c Detach) withReply(reply chan<- Ack) Command      { c.Reply = reply; return c }
+func (c Pause) withReply(reply chan<- Ack) Command       { c.Reply = reply; return c }
+func (c Resume) withReply(reply chan<- Ack) Command      { c.Reply = reply; return c }
+func (c TogglePause) withReply(reply chan<- Ack) Command { c.Reply = reply; return c }
+func (c Step) withReply(reply chan<- Ack) Command        { c.Reply = reply; return c }
+func (c StepBack) withReply(reply chan<- Ack) Command    { c.Reply = reply; return c }
+func (c SetSpeed) withReply(reply chan<- Ack) Command    { c.Reply = reply; return c }
+func (c ChangeSpeed) withReply(reply chan<- Ack) Command { c.Reply = reply; return c }
+func (c Animate) withReply(reply chan<- Ack) Command     { c.Reply = reply; return c }
+func (c snapshot) withReply(reply chan<- Ack) Command    { return c }
+
+// KeyCommand returns the command for a key press, or nil when the key does nothing.
+//
+//	's' saves the world
+//	'q' quits
+//	'p' pauses and resumes
+//	'k' shuts down
+//	'd' detaches from the broker
+//	'n' steps one turn
+//	'N' steps Params.StepTurns turns
+//	'b' steps back one turn
+//	'+' and '-' make the run faster and slower
+//	'g' starts and finishes an animation
+func KeyCommand(key rune) Command {
+	switch key {
+	case 's':
+		return Save{}
+	case 'q':
+		return Quit{}
+	case 'p':
+		return TogglePause{}
+	case 'k':
+		return Shutdown{}
+	case 'd':
+		return Detach{}
+	case 'n':
+		return Step{Turns: 1}
+	case 'N':
+		return Step{}
+	case 'b':
+		return StepBack{}
+	case '+':
+		return ChangeSpeed{Steps: 1}
+	case '-':
+		return ChangeSpeed{Steps: -1}
+	case 'g':
+		return Animate{}
+	}
+	return nil
+}
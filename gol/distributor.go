@@ -1,31 +1,69 @@
 package gol
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"math"
 	"time"
 
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// engine processes the turns of the Game of Life.
+// The full world is only put back together when it is asked for.
+type engine interface {
+	// nextTurn processes one turn, sending the cells that changed as CellsFlipped or CellFlipped events.
+	// An error means that the engine can no longer be used, such as when its broker cannot be reached.
+	nextTurn(turn int) error
+	world() ([][]uint8, error)
+	close()
+}
+
+// shutdowner is an engine running in other processes, which can be told to exit.
+type shutdowner interface {
+	shutdown() error
+}
+
+// detacher is an engine running in other processes, which can carry on without the controller.
+type detacher interface {
+	detach() error
+}
+
+// leaper is an engine that can process many turns at once.
+type leaper interface {
+	// leap processes at least one and at most max turns, returning how many were processed.
+	leap(turn, max int) int
+}
+
 type distributorChannels struct {
 	events     chan<- Event
 	ioCommand  chan<- ioCommand
 	ioIdle     <-chan bool
 	ioFilename chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	ioOutput   chan<- []uint8
+	ioInput    <-chan []uint8
+
+	ioCheckpoint chan<- checkpoint
+	ioAnimation  chan<- *animation
+	ioErrors     <-chan error
+
+	commands <-chan Command
 }
 
-func calculateNeighbours(width, y, x int, haloWorld [][]uint8) int {
-	height := len(haloWorld)
+func calculateNeighbours(width, y, x int, haloWorld [][]uint8, wrap bool) int {
 	neighbours := 0
 	for i := -1; i <= 1; i++ {
 		for j := -1; j <= 1; j++ {
 			if i != 0 || j != 0 {
-				h := (y + height + i) % height
-				w := (x + width + j) % width
-				if haloWorld[h][w] == 255 {
+				w := x + j
+				if w < 0 || w >= width {
+					if !wrap {
+						continue
+					}
+					w = (w + width) % width
+				}
+				if haloWorld[y+i][w] == 255 {
 					neighbours++
 				}
 			}
@@ -42,35 +80,87 @@ func makeMatrix(height, width int) [][]uint8 {
 	return matrix
 }
 
-func readPgmData(p Params, c distributorChannels, turn int, world [][]uint8) [][]uint8 {
+// readPgmData receives the world from the io goroutine one row at a time.
+func readPgmData(p Params, c distributorChannels, turn int) ([][]uint8, error) {
+	filename := inputPath(p)
 	c.ioCommand <- ioInput
-	c.ioFilename <- strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight)
-	for col := 0; col < p.ImageHeight; col++ {
-		for row := 0; row < p.ImageWidth; row++ {
-			data := <-c.ioInput
-			world[col][row] = data
-			if data == 255 {
-				c.events <- CellFlipped{turn, util.Cell{X: row, Y: col}}
+	c.ioFilename <- filename
+	world := make([][]uint8, p.ImageHeight)
+	flips := newFlips(p, c.events, turn)
+	for col := 0; col < p.ImageHeight; {
+		select {
+		case world[col] = <-c.ioInput:
+			for row, data := range world[col] {
+				if data == 255 {
+					flips.flip(row, col)
+				}
 			}
+			col++
+		case err := <-c.ioErrors:
+			// The io goroutine only finishes early when the file cannot be read.
+			return nil, reportIoError(c, turn, filename, err)
 		}
 	}
-	return world
+	if err := awaitIo(c, turn, filename); err != nil {
+		return nil, err
+	}
+	flips.send()
+	return world, nil
 }
 
-func writePgmData(p Params, c distributorChannels, turn int, world [][]uint8) {
-	filename := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight) + "x" + strconv.Itoa(p.Turns)
+// writePgmData sends the world to the io goroutine one row at a time.
+func writePgmData(p Params, c distributorChannels, turn int, world [][]uint8) error {
+	filename := outputPath(p, turn)
 	c.ioCommand <- ioOutput
 	c.ioFilename <- filename
 	for col := 0; col < p.ImageHeight; col++ {
-		for row := 0; row < p.ImageWidth; row++ {
-			if world[col][row] == 255 {
-				c.ioOutput <- 255
-			} else {
-				c.ioOutput <- 0
-			}
-		}
+		c.ioOutput <- world[col]
+	}
+	if err := awaitIo(c, turn, filename); err != nil {
+		return err
+	}
+	c.events <- ImageOutputComplete{turn, filename}
+	return nil
+}
+
+// saveCheckpoint sends the world to the io goroutine to be saved as a checkpoint.
+func saveCheckpoint(p Params, c distributorChannels, turn int, world [][]uint8) error {
+	filename := checkpointPath(p)
+	c.ioCommand <- ioCheckpoint
+	c.ioFilename <- filename
+	c.ioCheckpoint <- checkpoint{Params: p, CompletedTurns: turn, World: world}
+	if err := awaitIo(c, turn, filename); err != nil {
+		return err
+	}
+	c.events <- CheckpointOutputComplete{turn, filename}
+	return nil
+}
+
+// saveAnimation sends the frames of an animation to the io goroutine to be written out.
+func saveAnimation(p Params, c distributorChannels, turn int, a *animation) error {
+	filename := animationPath(p, a.turn)
+	c.ioCommand <- ioAnimation
+	c.ioFilename <- filename
+	c.ioAnimation <- a
+	if err := awaitIo(c, turn, filename); err != nil {
+		return err
 	}
 	c.events <- ImageOutputComplete{turn, filename}
+	return nil
+}
+
+// awaitIo waits for the io goroutine to say whether it could use the file, sending an IOError event when it could not.
+func awaitIo(c distributorChannels, turn int, filename string) error {
+	if err := <-c.ioErrors; err != nil {
+		return reportIoError(c, turn, filename, err)
+	}
+	return nil
+}
+
+func reportIoError(c distributorChannels, turn int, filename string, err error) error {
+	ioError := IOError{turn, filename, err}
+	c.events <- ioError
+	return ioError
 }
 
 func findAliveCells(p Params, world [][]uint8) []util.Cell {
@@ -85,140 +175,485 @@ func findAliveCells(p Params, world [][]uint8) []util.Cell {
 	return alive
 }
 
-
-func calculateNextState(p Params, c distributorChannels, startY, endY, turn int, worldCopy [][]uint8) [][]byte {
-	height := len(worldCopy)-2
+// calculateNextState writes the next state of the rows between the halo rows of worldCopy into the same rows of newWorld.
+func calculateNextState(p Params, worldCopy, newWorld [][]uint8, flip func(x, y int)) {
+	height := len(worldCopy) - 2
 	width := p.ImageWidth
-	newWorld := makeMatrix(height, width)
+	wrap := p.Topology.wrapsHorizontally()
 
-	for col, col1 := 0, 1; col < height; col, col1 = col+1, col1+1 {
+	for col := 1; col <= height; col++ {
 		for row := 0; row < width; row++ {
 
-			//startY+col gets the absolute y position when there is more than 1 worker
-			n := calculateNeighbours(width, col1, row, worldCopy)
-			currentState := worldCopy[col1][row]
+			n := calculateNeighbours(width, col, row, worldCopy, wrap)
+			currentState := worldCopy[col][row]
+			nextState := p.Rule.nextState(currentState, n)
+			newWorld[col][row] = nextState
 
-			if currentState == 255 {
-				if n == 2 || n == 3 {
-					newWorld[col][row] = 255
-				} else {
-					c.events <- CellFlipped{CompletedTurns: turn, Cell: util.Cell{X: row, Y: startY + col}}
-				}
-			}
-
-			if currentState == 0 {
-				if n == 3 {
-					newWorld[col][row] = 255
-					c.events <- CellFlipped{CompletedTurns: turn, Cell: util.Cell{X: row, Y: startY + col}}
-				}
+			if nextState != currentState && flip != nil {
+				flip(row, col-1)
 			}
 		}
 	}
+}
+
+// getWorkerBounds returns the rows of the world that worker j of n is responsible for.
+// The last worker also takes the extra rows when the height does not divide evenly.
+func getWorkerBounds(height, j, n int) (startY, endY int) {
+	workerHeight := height / n
+	startY = workerHeight * j
+	endY = workerHeight * (j + 1)
+	if j == n-1 {
+		endY += height % n
+	}
+	return startY, endY
+}
 
-	return newWorld
+// getNumberOfWorkers limits the requested number of workers so that every worker gets at least one row.
+func getNumberOfWorkers(p Params, requested int) int {
+	if requested > p.ImageHeight {
+		return p.ImageHeight
+	}
+	if requested < 1 {
+		return 1
+	}
+	return requested
 }
 
-func worker(p Params, c distributorChannels, startY, endY, turn int, worldCopy [][]uint8, out chan<- [][]uint8) {
-	newPixelData := calculateNextState(p, c, startY, endY, turn, worldCopy)
-	out <- newPixelData
+// turnsUntilStop returns how many turns can be processed before the run finishes or the next checkpoint or report is due.
+func turnsUntilStop(p Params, turn int) int {
+	turns := p.Turns - turn
+	for _, every := range []int{p.CheckpointEvery, p.ReportEvery} {
+		if every > 0 {
+			turns = minInt(turns, every-turn%every)
+		}
+	}
+	return turns
 }
 
-func getWorkerSlice(world [][]uint8, startY, endY, workerIndex, numberOfWorkers int)[][]uint8{
-	var workerSlice [][]uint8
-	worldHeight := len(world)
-	if workerIndex == 0 {
-		workerSlice = append(workerSlice, world[worldHeight-1])
-		workerSlice = append(workerSlice, world[startY:endY]...)
-		workerSlice = append(workerSlice, world[endY])
-	} else if workerIndex == numberOfWorkers-1 {
-		workerSlice = append(workerSlice, world[startY-1])
-		workerSlice = append(workerSlice, world[startY:endY]...)
-		workerSlice = append(workerSlice, world[0])
-	} else {
-		workerSlice = append(workerSlice, world[startY-1])
-		workerSlice = append(workerSlice, world[startY:endY]...)
-		workerSlice = append(workerSlice, world[endY])
+// startEngine starts the engine chosen by p on the world after the given number of turns.
+func startEngine(p Params, c distributorChannels, turn int, world [][]uint8) (engine, error) {
+	switch {
+	case p.Broker != "":
+		r, err := dialBroker(p, c, turn, world)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case p.Backend == Bitboard:
+		return startBitboard(p, c, world), nil
+	case p.Backend == HashLife:
+		return startHashLife(p, c, world), nil
+	default:
+		return startHaloWorkers(p, c, world), nil
 	}
-	return workerSlice
 }
 
-func playTurn(p Params, c distributorChannels, turn int, world [][]byte) [][]byte {
-	var newPixelData, worldCopy [][]uint8
-	if p.Threads == 1 {
-		worldCopy = append(worldCopy, world[len(world)-1])
-		worldCopy = append(worldCopy, world...)
-		worldCopy = append(worldCopy, world[0])
-		newPixelData = calculateNextState(p, c, 0, p.ImageHeight, turn, worldCopy)
+// distributor divides the work between workers and interacts with other goroutines.
+// The world is read from the image, or taken from resumed when carrying on from a checkpoint.
+// It returns the final world and the first file that could not be read or written,
+// or the world so far and the context's error when the context is cancelled,
+// or the error from the engine when it could not carry on, such as when the broker cannot be reached.
+func distributor(ctx context.Context, p Params, c distributorChannels, keyPresses <-chan rune, resumed *checkpoint) (Result, error) {
+
+	turn := 0
+	var world [][]uint8
+	if resumed != nil {
+		turn = resumed.CompletedTurns
+		world = resumed.World
+		flips := newFlips(p, c.events, turn)
+		for _, cell := range findAliveCells(p, world) {
+			flips.flip(cell.X, cell.Y)
+		}
+		flips.send()
 	} else {
+		var err error
+		world, err = readPgmData(p, c, turn)
+		if err != nil {
+			c.events <- StateChange{turn, Quitting}
+			close(c.events)
+			return Result{}, err
+		}
+	}
 
-		workerChannels := make([]chan [][]uint8, p.Threads)
-		for i := 0; i < p.Threads; i++ {
-			workerChannels[i] = make(chan [][]uint8)
+	// Output that fails is reported straight away, but the run carries on and the first failure is returned at the end.
+	var ioErr error
+	keep := func(err error) {
+		if ioErr == nil {
+			ioErr = err
+		}
+	}
+
+	engine, err := startEngine(p, c, turn, world)
+	if err != nil {
+		c.events <- StateChange{turn, Quitting}
+		close(c.events)
+		return Result{}, err
+	}
+	defer func() {
+		if engine != nil {
+			engine.close()
 		}
+	}()
+	// The run is executing once the initial board has been sent, which tells the initial board apart from the flips of the first turn.
+	c.events <- StateChange{turn, Executing}
 
-		workerHeight := p.ImageHeight / p.Threads
+	// engineErr is the first error from the engine, which ends the run without writing anything more out.
+	var engineErr error
+	fail := func(err error) bool {
+		if err != nil && engineErr == nil {
+			engineErr = err
+		}
+		return err != nil
+	}
+	// currentWorld returns a copy of the world from the engine, or nil once the engine has failed.
+	currentWorld := func() [][]uint8 {
+		if engineErr != nil {
+			return nil
+		}
+		current, err := engine.world()
+		if fail(err) {
+			return nil
+		}
+		return current
+	}
+
+	// Once it has been attached to, the broker is started afresh like any other run when stepping back.
+	p.Attach = false
+	previous := newHistory(p.History)
+
+	interval := p.ReportInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval) //send something down ticker.C channel every interval
+	defer ticker.Stop()
+	stats := newReporter(p, turn, world)
 
-		for j := 0; j < p.Threads; j++ {
-			startHeight := workerHeight * j
-			endHeight := workerHeight * (j + 1)
-			if j == p.Threads-1 { // send the extra part when workerHeight is not a whole number in last iteration
-				endHeight += p.ImageHeight % p.Threads
+	// animating holds the frames of the animation being made, when there is one.
+	var animating *animation
+	if p.Animation != "" {
+		animating = newAnimation(p, p.AnimateFrom, p.AnimateTo)
+	}
+	finishAnimation := func() error {
+		a := animating
+		animating = nil
+		if len(a.frames) == 0 {
+			return nil
+		}
+		return saveAnimation(p, c, turn, a)
+	}
+	// animate adds a frame when one is due, and writes the animation out once its last frame has been added.
+	animate := func() {
+		if animating == nil {
+			return
+		}
+		if animating.due(turn) {
+			if current := currentWorld(); current != nil {
+				animating.add(turn, current)
 			}
-			worldCopy = getWorkerSlice(world, startHeight, endHeight, j, p.Threads)
-			go worker(p, c, startHeight, endHeight, turn, worldCopy, workerChannels[j])
 		}
+		if animating.finished(turn) {
+			keep(finishAnimation())
+		}
+	}
+	animate()
+
+	// throttle is set after each turn when the speed is limited, and no turns are processed until it fires.
+	// pacing sets it to fire when the next turn is due, so that the turns keep to the speed however long they take.
+	speed := p.TurnsPerSecond
+	var throttle <-chan time.Time
+	var pacing pace
 
-		for k := 0; k < p.Threads; k++ {
-			result := <-workerChannels[k]
-			newPixelData = append(newPixelData, result...)
+	paused := false
+	setPaused := func(pause bool) {
+		if pause != paused {
+			paused = pause
+			pacing.reset()
+			if paused {
+				c.events <- StateChange{turn, Paused}
+			} else {
+				// The boards kept while paused are forgotten, as the turns processed from now on are not kept.
+				previous = newHistory(p.History)
+				c.events <- StateChange{turn, Executing}
+			}
 		}
 	}
 
-	return newPixelData
-}
+	// Steps are acknowledged once all of the turns they asked for have been processed.
+	stepsLeft := 0
+	var steps []Command
 
-// distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels, keyPresses <-chan rune) {
+	// detached is set when the broker has been left to finish the run, so the final world is not written out.
+	detached := false
 
-	initialWorld := makeMatrix(p.ImageHeight, p.ImageWidth)
-	turn := 0
-	world := readPgmData(p, c, turn, initialWorld)
-	ticker := time.NewTicker(2 * time.Second) //send something down ticker.C channel every 2 seconds
+	// quit is set when the world has already been written out by Quit or Shutdown.
+	// A broker that has been shut down has exited, so the world cannot be asked for again.
+	quit := false
+
+	acknowledge := func(command Command, err error) {
+		if reply := command.reply(); reply != nil {
+			reply <- Ack{turn, err}
+		}
+	}
+
+	// apply applies a command between turns, returning true when the run should stop.
+	apply := func(command Command) bool {
+		switch command := command.(type) {
+		case Save:
+			current := currentWorld()
+			if current == nil {
+				acknowledge(command, engineErr)
+				break
+			}
+			fmt.Println("Starting output")
+			err := writePgmData(p, c, turn, current)
+			keep(err)
+			acknowledge(command, err)
+		case Quit, Shutdown:
+			current := currentWorld()
+			if current == nil {
+				acknowledge(command, engineErr)
+				return true
+			}
+			world = current
+			err := writePgmData(p, c, turn, world)
+			if checkpointErr := saveCheckpoint(p, c, turn, world); err == nil {
+				err = checkpointErr
+			}
+			keep(err)
+			if _, shutdown := command.(Shutdown); shutdown {
+				if remote, ok := engine.(shutdowner); ok {
+					if shutdownErr := remote.shutdown(); err == nil {
+						err = shutdownErr
+					}
+				}
+			}
+			quit = true
+			c.events <- StateChange{turn, Quitting}
+			acknowledge(command, err)
+			return true
+		case Detach:
+			remote, ok := engine.(detacher)
+			if !ok {
+				acknowledge(command, errors.New("only a run on a broker can be detached from"))
+				break
+			}
+			current := currentWorld()
+			if current == nil {
+				acknowledge(command, engineErr)
+				return true
+			}
+			world = current
+			if err := remote.detach(); err != nil {
+				acknowledge(command, err)
+				break
+			}
+			detached = true
+			acknowledge(command, nil)
+			return true
+		case Animate:
+			if animating == nil {
+				animating = newAnimation(p, turn, 0)
+				animate()
+				acknowledge(command, nil)
+				break
+			}
+			err := finishAnimation()
+			keep(err)
+			acknowledge(command, err)
+		case Pause:
+			setPaused(true)
+			acknowledge(command, nil)
+		case Resume:
+			setPaused(false)
+			acknowledge(command, nil)
+		case TogglePause:
+			setPaused(!paused)
+			acknowledge(command, nil)
+		case Step:
+			turns := command.Turns
+			if turns == 0 {
+				turns = p.StepTurns
+				if turns == 0 {
+					turns = 10
+				}
+			}
+			if turns < 0 {
+				acknowledge(command, nil)
+			} else {
+				stepsLeft += turns
+				steps = append(steps, command)
+			}
+		case StepBack:
+			setPaused(true)
+			board, ok := previous.pop()
+			if !ok {
+				acknowledge(command, errors.New("no earlier board to step back to"))
+				break
+			}
+			current := currentWorld()
+			if current == nil {
+				acknowledge(command, engineErr)
+				return true
+			}
+			engine.close()
+			turn = board.CompletedTurns
+			engine, err = startEngine(p, c, turn, board.World)
+			if fail(err) {
+				acknowledge(command, err)
+				return true
+			}
+			stats.rebase(board.World)
+			flips := newFlips(p, c.events, turn)
+			for y := range current {
+				for x := range current[y] {
+					if current[y][x] != board.World[y][x] {
+						flips.flip(x, y)
+					}
+				}
+			}
+			flips.send()
+			if p.sends(TurnEvents) {
+				c.events <- TurnComplete{turn}
+			}
+			acknowledge(command, nil)
+		case SetSpeed:
+			speed = command.TurnsPerSecond
+			throttle = nil
+			pacing.reset()
+			acknowledge(command, nil)
+		case ChangeSpeed:
+			speed = changeSpeed(speed, command.Steps)
+			throttle = nil
+			pacing.reset()
+			acknowledge(command, nil)
+		case snapshot:
+			command.result <- Result{turn, currentWorld()}
+		}
+		return false
+	}
+
+	// running is always ready, so that a turn is processed whenever nothing else needs doing.
+	running := make(chan struct{})
+	close(running)
 
 NextTurnLoop:
-	for turn < p.Turns {
+	for turn < p.Turns && engineErr == nil {
+		var turnReady <-chan struct{}
+		if (!paused || stepsLeft > 0) && throttle == nil {
+			turnReady = running
+		}
+
 		select {
+		case <-ctx.Done():
+			if current := currentWorld(); current != nil {
+				world = current
+			}
+			c.events <- StateChange{turn, Quitting}
+			close(c.events)
+			return Result{turn, world}, ctx.Err()
 		case <-ticker.C:
-			c.events <- AliveCellsCount{turn, len(findAliveCells(p, world))}
+			if !p.sends(ReportEvents) {
+				break
+			}
+			if current := currentWorld(); current != nil {
+				report := stats.report(turn, current)
+				c.events <- AliveCellsCount{turn, report.Alive, speed}
+				c.events <- report
+			}
 		case key := <-keyPresses:
-			if key == 's' {
-				fmt.Println("Starting output")
-				writePgmData(p, c, turn, world)
+			if command := KeyCommand(key); command != nil && apply(command) {
+				break NextTurnLoop
 			}
-			if key == 'q' {
-				writePgmData(p, c, turn, world)
-				c.events <- StateChange{turn, Quitting}
+		case command := <-c.commands:
+			if apply(command) {
 				break NextTurnLoop
 			}
-			if key == 'p' {
-				c.events <- StateChange{turn, Paused}
-				for {
-					await := <-keyPresses
-					if await == int32(112) {
-						c.events <- StateChange{turn, Executing}
-						break
+		case <-throttle:
+			throttle = nil
+		case <-turnReady:
+			// Boards are only kept while paused, so that a run that is not being stepped through does not copy the world every turn.
+			if paused && previous != nil {
+				current := currentWorld()
+				if current == nil {
+					break
+				}
+				previous.push(turn, current)
+			}
+			turns := 1
+			if leaper, ok := engine.(leaper); ok {
+				max := turnsUntilStop(p, turn)
+				if paused && stepsLeft < max {
+					max = stepsLeft
+				}
+				// While paused, every turn is kept so that each one can be stepped back through.
+				if paused && previous != nil {
+					max = 1
+				}
+				if animating != nil {
+					max = minInt(max, animating.turnsUntilFrame(turn))
+				}
+				if speed > 0 && int(math.Ceil(speed)) < max {
+					max = int(math.Ceil(speed))
+				}
+				turns = leaper.leap(turn, max)
+			} else if fail(engine.nextTurn(turn)) {
+				break
+			}
+			turn += turns
+			if p.sends(ReportEvents) {
+				current := currentWorld()
+				if current == nil {
+					break
+				}
+				stats.count(current)
+			}
+			if p.sends(TurnEvents) {
+				c.events <- TurnComplete{turn}
+			}
+			animate()
+			if p.CheckpointEvery > 0 && turn%p.CheckpointEvery == 0 {
+				if current := currentWorld(); current != nil {
+					keep(saveCheckpoint(p, c, turn, current))
+				}
+			}
+			if p.ReportEvery > 0 && turn%p.ReportEvery == 0 && p.sends(ReportEvents) {
+				c.events <- stats.report(turn, stats.world)
+			}
+			if stepsLeft > 0 {
+				stepsLeft -= turns
+				if stepsLeft <= 0 {
+					stepsLeft = 0
+					for _, step := range steps {
+						acknowledge(step, nil)
 					}
+					steps = nil
 				}
 			}
-		default:
-			world = playTurn(p, c, turn, world)
-			turn++
-			c.events <- TurnComplete{turn}
+			if speed > 0 {
+				throttle = pacing.after(turns, speed)
+			}
 		}
 	}
-	
-	c.events <- FinalTurnComplete{turn, findAliveCells(p, world)}
-	writePgmData(p, c, turn, world) // This line needed if out/ does not have files
+
+	// Steps that were cut short by the end of the run are still acknowledged.
+	for _, step := range steps {
+		acknowledge(step, nil)
+	}
+
+	if quit {
+		c.events <- FinalTurnComplete{turn, findAliveCells(p, world)}
+	} else if !detached {
+		if current := currentWorld(); current != nil {
+			world = current
+			c.events <- FinalTurnComplete{turn, findAliveCells(p, world)}
+			keep(writePgmData(p, c, turn, world)) // This line needed if out/ does not have files
+		}
+	}
+
+	if animating != nil && engineErr == nil {
+		keep(finishAnimation())
+	}
 
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
@@ -228,4 +663,8 @@ NextTurnLoop:
 
 	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
 	close(c.events)
+	if engineErr != nil {
+		return Result{turn, world}, engineErr
+	}
+	return Result{turn, world}, ioErr
 }
@@ -2,6 +2,8 @@ package gol
 
 import (
 	"fmt"
+	"strings"
+
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -16,9 +18,27 @@ type Event interface {
 
 // AliveCellsCount is an Event notifying the user about the number of currently alive cells.
 // This Event should be sent every 2s.
+// TurnsPerSecond is the current limit on how many turns are processed every second, which is 0 when there is no limit.
 type AliveCellsCount struct { // implements Event
 	CompletedTurns int
 	CellsCount     int
+	TurnsPerSecond float64
+}
+
+// StatsReport is an Event giving statistics about the world, sent along with every AliveCellsCount
+// and also after every Params.ReportEvery turns.
+// Births and Deaths add up the cells born and died in every turn since the last report,
+// so an oscillator that is back where it was at the last report still counts every cell that changed on the way.
+// The HashLife backend leaps over turns without working each one out, so it counts them across each leap instead.
+// TurnsPerSecond is how fast the turns have been processed since the last report.
+// Min and Max are the corners of the smallest rectangle holding every alive cell, and are both zero when there are none.
+type StatsReport struct { // implements Event
+	CompletedTurns int
+	Alive          int
+	Births         int
+	Deaths         int
+	TurnsPerSecond float64
+	Min, Max       util.Cell
 }
 
 // ImageOutputComplete is an Event notifying the user about the completion of output.
@@ -28,6 +48,46 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// CheckpointOutputComplete is an Event notifying the user that a checkpoint has been saved.
+// This Event should be sent every time a checkpoint has been saved, so the run can be resumed from it.
+type CheckpointOutputComplete struct { // implements Event
+	CompletedTurns int
+	Filename       string
+}
+
+// IOError is an Event notifying the user that a file could not be read or written.
+// The run stops when the world cannot be loaded, and carries on when output fails.
+type IOError struct { // implements Event and error
+	CompletedTurns int
+	Filename       string
+	Err            error
+}
+
+// WorkersLost is an Event notifying the user that worker servers in a distributed run have failed.
+// Their strips have been split between the workers that are left, and the turn was processed again from the last board they all finished.
+type WorkersLost struct { // implements Event
+	CompletedTurns int
+	Lost           []string
+	Workers        int
+}
+
+// EventKind is a set of kinds of events, which Params.Events uses to say which ones should be sent.
+type EventKind int
+
+const (
+	// StateEvents are StateChange, FinalTurnComplete, IOError, ImageOutputComplete, CheckpointOutputComplete
+	// and WorkersLost events. They are always sent.
+	StateEvents EventKind = 1 << iota
+	// FlipEvents are CellsFlipped and CellFlipped events.
+	FlipEvents
+	// TurnEvents are TurnComplete events.
+	TurnEvents
+	// ReportEvents are AliveCellsCount and StatsReport events.
+	ReportEvents
+
+	AllEvents = StateEvents | FlipEvents | TurnEvents | ReportEvents
+)
+
 // State represents a change in the state of execution.
 type State int
 
@@ -52,6 +112,14 @@ type CellFlipped struct { // implements Event
 	Cell           util.Cell
 }
 
+// CellsFlipped is an Event notifying the GUI about a change of state of many cells at once.
+// It is sent instead of CellFlipped unless Params.CellFlippedEvents is set,
+// once every turn by each worker with all of the cells it changed, and once with all of the cells alive when the image is loaded in.
+type CellsFlipped struct { // implements Event
+	CompletedTurns int
+	Cells          []util.Cell
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
 // All CellFlipped events must be sent *before* TurnComplete.
@@ -91,6 +159,9 @@ func (event StateChange) GetCompletedTurns() int {
 }
 
 func (event AliveCellsCount) String() string {
+	if event.TurnsPerSecond > 0 {
+		return fmt.Sprintf("Alive Cells %v at %v turns per second", event.CellsCount, event.TurnsPerSecond)
+	}
 	return fmt.Sprintf("Alive Cells %v", event.CellsCount)
 }
 
@@ -98,6 +169,15 @@ func (event AliveCellsCount) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event StatsReport) String() string {
+	return fmt.Sprintf("Alive %v, %v births, %v deaths, %.1f turns per second, inside (%v, %v) to (%v, %v)",
+		event.Alive, event.Births, event.Deaths, event.TurnsPerSecond, event.Min.X, event.Min.Y, event.Max.X, event.Max.Y)
+}
+
+func (event StatsReport) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event ImageOutputComplete) String() string {
 	return fmt.Sprintf("File %v output complete", event.Filename)
 }
@@ -106,6 +186,34 @@ func (event ImageOutputComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CheckpointOutputComplete) String() string {
+	return fmt.Sprintf("Checkpoint %v output complete", event.Filename)
+}
+
+func (event CheckpointOutputComplete) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event IOError) String() string {
+	return fmt.Sprintf("Error with file %v: %v", event.Filename, event.Err)
+}
+
+func (event IOError) Error() string {
+	return event.String()
+}
+
+func (event IOError) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event WorkersLost) String() string {
+	return fmt.Sprintf("Lost workers %v, %v left", strings.Join(event.Lost, ", "), event.Workers)
+}
+
+func (event WorkersLost) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event CellFlipped) String() string {
 	return fmt.Sprintf("")
 }
@@ -114,6 +222,14 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellsFlipped) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event CellsFlipped) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -0,0 +1,40 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// flips collects the cells changed by one worker in one turn and sends them together as a CellsFlipped event,
+// or sends a CellFlipped event for each of them straight away when p.CellFlippedEvents is set.
+// It is nil when p does not send FlipEvents, and then nothing is collected or sent.
+type flips struct {
+	events  chan<- Event
+	turn    int
+	perCell bool
+	cells   []util.Cell
+}
+
+func newFlips(p Params, events chan<- Event, turn int) *flips {
+	if !p.sends(FlipEvents) {
+		return nil
+	}
+	return &flips{events: events, turn: turn, perCell: p.CellFlippedEvents}
+}
+
+func (f *flips) flip(x, y int) {
+	if f == nil {
+		return
+	}
+	cell := util.Cell{X: x, Y: y}
+	if f.perCell {
+		f.events <- CellFlipped{f.turn, cell}
+	} else {
+		f.cells = append(f.cells, cell)
+	}
+}
+
+// send sends the cells collected so far as a CellsFlipped event, unless there are none.
+func (f *flips) send() {
+	if f != nil && len(f.cells) > 0 {
+		f.events <- CellsFlipped{f.turn, f.cells}
+		f.cells = nil
+	}
+}
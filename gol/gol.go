@@ -1,39 +1,264 @@
 package gol
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+
+	// Rule decides which cells are born and which survive. Conway's Game of Life is used when it is not set.
+	Rule Rule
+
+	// RuleGiven keeps Rule even when it is the zero Rule, B/S, which otherwise stands for a rule that was not given.
+	// It is set by LoadParams once the rule has been decided.
+	RuleGiven bool
+
+	// Topology decides how the edges of the board are joined. The board is a torus by default.
+	Topology Topology
+
+	// Backend selects how the turns are processed on this machine. Strips are used by default.
+	Backend Backend
+
+	// Broker is the address of a broker to process the turns on.
+	// The turns are processed locally when it is empty.
+	Broker string
+
+	// Attach takes over the run on Broker from a controller that detached, instead of loading an image.
+	// The size, rule and topology are then taken from the broker, and so is the number of turns when Turns is 0.
+	Attach bool
+
+	// Resume is the path of a checkpoint to carry on from instead of loading an image.
+	// The size, rule and topology are then taken from the checkpoint, and so is the number of turns when Turns is 0.
+	Resume string
+
+	// TurnsPerSecond is the most turns to process every second. There is no limit when it is 0.
+	TurnsPerSecond float64
+
+	// Events are the kinds of events to send. Every kind is sent when it is 0.
+	// Leaving out FlipEvents saves the workers from keeping track of the cells that change.
+	Events EventKind
+
+	// CellFlippedEvents sends a CellFlipped event for every cell that changes,
+	// instead of a CellsFlipped event from each worker every turn.
+	CellFlippedEvents bool
+
+	// ReportInterval is how often AliveCellsCount and StatsReport events are sent. It is 2 seconds when it is 0.
+	// The births and deaths in every turn are counted for the reports, so the world is read after every turn while they are sent.
+	ReportInterval time.Duration
+
+	// ReportEvery also sends a StatsReport event after every ReportEvery turns. They are only sent on the interval when it is 0.
+	ReportEvery int
+
+	// StepTurns is how many turns 'N' steps through while paused. It is 10 when it is 0.
+	StepTurns int
+
+	// History is how many earlier boards are kept so that 'b' can step back through the turns stepped through while paused.
+	// No boards are kept when it is 0. They are only kept while the run is paused, and are forgotten when it carries on.
+	History int
+
+	// CheckpointEvery saves a checkpoint after every CheckpointEvery turns.
+	// A checkpoint is always saved when quitting with 'q'.
+	CheckpointEvery int
+
+	// Pattern is the path of an RLE, Life 1.06, plaintext or pgm pattern to place into an empty world instead of loading an image.
+	// The rule in an RLE pattern is used when Rule is not set or given, and the size of the pattern when the width or height is 0.
+	Pattern string
+
+	// PatternX and PatternY are where the top left corner of the pattern is placed, unless CentrePattern is set.
+	PatternX, PatternY int
+	CentrePattern      bool
+
+	// OutputFormat is the format that the world is written out in.
+	// Pgm images are written by default, unless Output ends with the extension of another format.
+	OutputFormat Format
+
+	// AliveThreshold is the grey level from 1 to 255 at or above which a cell in a greyscale image is alive.
+	// It is scaled to the maxval of the image, and is 128 when it is 0. Black pixels are alive in bitmap images.
+	AliveThreshold int
+
+	// Input is the pgm or pbm image to load, or a directory holding it. It is images/{w}x{h}.pgm by default,
+	// where {w} and {h} are replaced by the width and height. When either is 0 it is read from the image.
+	Input string
+
+	// Animation is the file to write an animated GIF of the run to, or an APNG when it ends with .png.
+	// {w}, {h}, {turn} and {turns} are replaced like in Output, where {turn} is the turn of the last frame.
+	// No animation is made when it is empty, although 'g' can still start one, which is written in the output directory.
+	Animation string
+
+	// A frame of the animation is added every AnimateEvery turns from AnimateFrom to AnimateTo,
+	// or to the end of the run when AnimateTo is 0. A frame is added every turn when AnimateEvery is 0.
+	// The animation is written out early once its frames hold 64 Mi cells between them, such as 256 frames of a 512x512 world.
+	AnimateFrom, AnimateTo, AnimateEvery int
+
+	// AnimateScale is how many pixels wide and high each cell is drawn in the animation. It is 1 when it is 0.
+	AnimateScale int
+
+	// AliveColour and DeadColour are the colours of the cells in the animation.
+	// Alive cells are white and dead cells black when they are the same.
+	AliveColour, DeadColour Colour
+
+	// Output is the file to write the world to, or a directory to write it in. It is out/{w}x{h}x{turns} by default,
+	// where {turn} is also replaced by the turns completed so far. The extension of the format is added when there is none.
+	// Checkpoints are saved in the same directory.
+	Output string
+}
+
+// sends returns whether events of the given kind should be sent.
+func (p Params) sends(kind EventKind) bool {
+	return p.Events == 0 || p.Events&kind != 0
+}
+
+// LoadParams returns p with the details that come from its files filled in,
+// such as the size of the input image or the rule of a pattern, without starting a run.
+// It also returns the world that was read to start from, which is nil when the input image is read once the run starts.
+// The world should be handed to RunFrom, so that the files are not read again and a broker is not attached to a second time.
+func LoadParams(p Params) (Params, *Result, error) {
+	p, start, err := load(p)
+	if err != nil || start == nil {
+		return p, nil, err
+	}
+	return p, &Result{CompletedTurns: start.CompletedTurns, Board: start.World}, nil
+}
+
+// load reads the files that decide the details of p and returns the world to start from,
+// which is nil when the world should be read from the input image.
+func load(p Params) (Params, *checkpoint, error) {
+	var start *checkpoint
+	if p.Attach {
+		cp, err := attachBroker(p.Broker)
+		if err != nil {
+			return p, nil, fmt.Errorf("cannot attach to broker %v: %v", p.Broker, err)
+		}
+		p = cp.resume(p)
+		start = &cp
+	} else if p.Resume != "" {
+		cp, err := readCheckpoint(p.Resume)
+		if err != nil {
+			return p, nil, IOError{0, p.Resume, err}
+		}
+		p = cp.resume(p)
+		start = &cp
+	} else if p.Pattern != "" {
+		pat, err := readPattern(p.Pattern, p.AliveThreshold)
+		if err != nil {
+			return p, nil, IOError{0, p.Pattern, err}
+		}
+		if p.ImageWidth == 0 {
+			p.ImageWidth = pat.Width
+		}
+		if p.ImageHeight == 0 {
+			p.ImageHeight = pat.Height
+		}
+		if !p.RuleGiven && p.Rule == (Rule{}) && pat.HasRule {
+			p.Rule, p.RuleGiven = pat.Rule, true
+		}
+		world, err := placePattern(p, pat)
+		if err != nil {
+			return p, nil, IOError{0, p.Pattern, err}
+		}
+		// A pattern is started like a checkpoint saved before the first turn.
+		start = &checkpoint{Params: p, World: world}
+	} else {
+		var err error
+		if p, err = imageSize(p); err != nil {
+			return p, nil, IOError{0, inputTemplate(p), err}
+		}
+	}
+	p.OutputFormat = outputFormat(p)
+	if !p.RuleGiven && p.Rule == (Rule{}) {
+		p.Rule = Conway
+	}
+	p.RuleGiven = true
+	if p.Backend == HashLife && p.Broker == "" && p.Topology != Torus {
+		return p, nil, fmt.Errorf("the %v backend only supports the %v topology, not %v", HashLife, Torus, p.Topology)
+	}
+	return p, start, nil
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
-func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+// Any file that cannot be read or written is sent as an IOError event, and the first one is returned once the events channel is closed.
+// The key presses are turned into commands by KeyCommand.
+func Run(p Params, events chan<- Event, keyPresses <-chan rune) error {
+	_, err := run(context.Background(), p, nil, events, keyPresses, nil)
+	return err
+}
+
+// RunWithCommands starts the processing of Game of Life like Run, but is controlled by commands instead of key presses.
+// Commands should not be sent once the events channel has been closed.
+func RunWithCommands(p Params, events chan<- Event, commands <-chan Command) error {
+	_, err := run(context.Background(), p, nil, events, nil, commands)
+	return err
+}
+
+// RunFrom starts the processing like RunWithCommands, from the Params and the world returned by LoadParams.
+// The input image is read as usual when start is nil.
+func RunFrom(p Params, start *Result, events chan<- Event, commands <-chan Command) error {
+	var resumed *checkpoint
+	if start != nil {
+		resumed = &checkpoint{Params: p, CompletedTurns: start.CompletedTurns, World: start.Board}
+	}
+	_, err := run(context.Background(), p, resumed, events, nil, commands)
+	return err
+}
+
+// run processes the turns until they are finished, the user quits or the context is cancelled.
+// The files are read by load unless the world to start from is given.
+func run(ctx context.Context, p Params, resumed *checkpoint, events chan<- Event, keyPresses <-chan rune, commands <-chan Command) (Result, error) {
+	var err error
+	if resumed == nil {
+		p, resumed, err = load(p)
+	}
+	if err != nil {
+		if ioError, ok := err.(IOError); ok {
+			events <- ioError
+		}
+		events <- StateChange{0, Quitting}
+		close(events)
+		return Result{}, err
+	}
 
 	fname := make(chan string)
-	out := make(chan uint8)
-	in := make(chan uint8)
+	out := make(chan []uint8)
+	in := make(chan []uint8)
+	checkpoints := make(chan checkpoint)
+	animations := make(chan *animation)
+	ioErrors := make(chan error)
 
 	ioCommand := make(chan ioCommand)
 	ioIdle := make(chan bool)
+	// Closing the commands stops the io goroutine once the distributor has finished with it.
+	defer close(ioCommand)
 
 	ioChannels := ioChannels{
-		command:  ioCommand,
-		idle:     ioIdle,
-		filename: fname,
-		output:   out,
-		input:    in,
+		command:    ioCommand,
+		idle:       ioIdle,
+		filename:   fname,
+		output:     out,
+		input:      in,
+		checkpoint: checkpoints,
+		animation:  animations,
+		errors:     ioErrors,
 	}
 	go startIo(p, ioChannels)
 
 	distributorChannels := distributorChannels{
-		events:     events,
-		ioCommand:  ioCommand,
-		ioIdle:     ioIdle,
-		ioFilename: fname,
-		ioOutput:   out,
-		ioInput:    in,
-	}
-	distributor(p, distributorChannels, keyPresses)
+		events:       events,
+		ioCommand:    ioCommand,
+		ioIdle:       ioIdle,
+		ioFilename:   fname,
+		ioOutput:     out,
+		ioInput:      in,
+		ioCheckpoint: checkpoints,
+		ioAnimation:  animations,
+		ioErrors:     ioErrors,
+		commands:     commands,
+	}
+	return distributor(ctx, p, distributorChannels, keyPresses, resumed)
 }
@@ -0,0 +1,278 @@
+package gol
+
+// node is a square of 2^level by 2^level cells in a HashLife quadtree.
+// Nodes are never changed once made and equal squares share the same node, so results can be memoized by pointer.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint
+	alive          bool
+}
+
+type resultKey struct {
+	node *node
+	step uint
+}
+
+// maxNodes is the number of nodes after which the memoized quadtree is thrown away and started again.
+const maxNodes = 1 << 22
+
+// universe makes canonical nodes and remembers how they evolve.
+type universe struct {
+	rule        Rule
+	dead, alive *node
+	nodes       map[[4]*node]*node
+	results     map[resultKey]*node
+}
+
+func newUniverse(rule Rule) *universe {
+	return &universe{
+		rule:    rule,
+		dead:    &node{},
+		alive:   &node{alive: true},
+		nodes:   make(map[[4]*node]*node),
+		results: make(map[resultKey]*node),
+	}
+}
+
+// join returns the canonical node made of four quadrants of the same level.
+func (u *universe) join(nw, ne, sw, se *node) *node {
+	key := [4]*node{nw, ne, sw, se}
+	if n, ok := u.nodes[key]; ok {
+		return n
+	}
+	n := &node{nw: nw, ne: ne, sw: sw, se: se, level: nw.level + 1}
+	u.nodes[key] = n
+	return n
+}
+
+// centre returns the node of half the size in the middle of n.
+func (u *universe) centre(n *node) *node {
+	return u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// centreHorizontal returns the node of the same level halfway between w and the node e to its right.
+func (u *universe) centreHorizontal(w, e *node) *node {
+	return u.join(w.ne, e.nw, w.se, e.sw)
+}
+
+// centreVertical returns the node of the same level halfway between n and the node s below it.
+func (u *universe) centreVertical(n, s *node) *node {
+	return u.join(n.sw, n.se, s.nw, s.ne)
+}
+
+// nextGeneration returns the centre of n after 2^step turns, where step is at most n.level-2.
+func (u *universe) nextGeneration(n *node, step uint) *node {
+	key := resultKey{n, step}
+	if result, ok := u.results[key]; ok {
+		return result
+	}
+
+	var result *node
+	if n.level == 2 {
+		result = u.base(n)
+	} else {
+		// The nine overlapping nodes of half the size that cover n.
+		n00, n01, n02 := n.nw, u.centreHorizontal(n.nw, n.ne), n.ne
+		n10, n11, n12 := u.centreVertical(n.nw, n.sw), u.centre(n), u.centreVertical(n.ne, n.se)
+		n20, n21, n22 := n.sw, u.centreHorizontal(n.sw, n.se), n.se
+
+		// At full speed both halves of the turns happen here, otherwise the first half is skipped.
+		inner := func(m *node) *node {
+			if step == n.level-2 {
+				return u.nextGeneration(m, step-1)
+			}
+			return u.centre(m)
+		}
+		r00, r01, r02 := inner(n00), inner(n01), inner(n02)
+		r10, r11, r12 := inner(n10), inner(n11), inner(n12)
+		r20, r21, r22 := inner(n20), inner(n21), inner(n22)
+
+		outerStep := step
+		if step == n.level-2 {
+			outerStep = step - 1
+		}
+		result = u.join(
+			u.nextGeneration(u.join(r00, r01, r10, r11), outerStep),
+			u.nextGeneration(u.join(r01, r02, r11, r12), outerStep),
+			u.nextGeneration(u.join(r10, r11, r20, r21), outerStep),
+			u.nextGeneration(u.join(r11, r12, r21, r22), outerStep),
+		)
+	}
+
+	u.results[key] = result
+	return result
+}
+
+// base returns the 2x2 centre of a 4x4 node after one turn.
+func (u *universe) base(n *node) *node {
+	var cells [4][4]uint8
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if cellAt(n, x, y) {
+				cells[y][x] = 255
+			}
+		}
+	}
+
+	var next [2][2]*node
+	for y := 1; y <= 2; y++ {
+		for x := 1; x <= 2; x++ {
+			neighbours := 0
+			for i := -1; i <= 1; i++ {
+				for j := -1; j <= 1; j++ {
+					if (i != 0 || j != 0) && cells[y+i][x+j] == 255 {
+						neighbours++
+					}
+				}
+			}
+			next[y-1][x-1] = u.dead
+			if u.rule.nextState(cells[y][x], neighbours) == 255 {
+				next[y-1][x-1] = u.alive
+			}
+		}
+	}
+	return u.join(next[0][0], next[0][1], next[1][0], next[1][1])
+}
+
+// cellAt reports whether the cell at (x, y) inside n is alive.
+func cellAt(n *node, x, y int) bool {
+	for n.level > 0 {
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n.alive
+}
+
+// hashLife is the engine that moves the world on by whole powers of two turns at once using HashLife.
+// The torus is tiled across a quadtree big enough for the light cone of the turns being processed,
+// so the centre of the result still holds a full copy of the world.
+type hashLife struct {
+	p        Params
+	c        distributorChannels
+	universe *universe
+	cells    [][]uint8
+}
+
+// maxStep keeps the quadtree coordinates well inside an int.
+const maxStep = 60
+
+// startHashLife starts the engine on a torus. Other topologies are turned down by load before a run starts.
+func startHashLife(p Params, c distributorChannels, world [][]uint8) *hashLife {
+	h := &hashLife{
+		p:        p,
+		c:        c,
+		universe: newUniverse(p.Rule),
+		cells:    makeMatrix(p.ImageHeight, p.ImageWidth),
+	}
+	for y := range world {
+		copy(h.cells[y], world[y])
+	}
+	return h
+}
+
+type tileKey struct {
+	level uint
+	x, y  int
+}
+
+// tile builds the node of the given level covering the world repeated in every direction.
+func (h *hashLife) tile(level uint) *node {
+	width, height := h.p.ImageWidth, h.p.ImageHeight
+	tiles := make(map[tileKey]*node)
+	var build func(level uint, x, y int) *node
+	build = func(level uint, x, y int) *node {
+		if level == 0 {
+			if h.cells[y%height][x%width] == 255 {
+				return h.universe.alive
+			}
+			return h.universe.dead
+		}
+		key := tileKey{level, x % width, y % height}
+		if n, ok := tiles[key]; ok {
+			return n
+		}
+		half := 1 << (level - 1)
+		n := h.universe.join(
+			build(level-1, x, y),
+			build(level-1, x+half, y),
+			build(level-1, x, y+half),
+			build(level-1, x+half, y+half),
+		)
+		tiles[key] = n
+		return n
+	}
+	return build(level, 0, 0)
+}
+
+// leap processes the largest power of two of turns that is no more than max,
+// so that the turns it stops at only depend on the turns that were asked for.
+func (h *hashLife) leap(turn, max int) int {
+	step := uint(0)
+	for step < maxStep && 1<<(step+1) <= max {
+		step++
+	}
+
+	// The centre of the result must be at least as big as the world.
+	size := h.p.ImageWidth
+	if h.p.ImageHeight > size {
+		size = h.p.ImageHeight
+	}
+	level := step + 2
+	for 1<<(level-1) < size {
+		level++
+	}
+
+	if len(h.universe.nodes) > maxNodes {
+		h.universe = newUniverse(h.p.Rule)
+	}
+
+	result := h.universe.nextGeneration(h.tile(level), step)
+	h.update(turn, result, 1<<(level-2))
+	return 1 << step
+}
+
+// update copies the world out of the centre of a result, which starts at the given offset in the tiling.
+func (h *hashLife) update(turn int, result *node, offset int) {
+	width, height := h.p.ImageWidth, h.p.ImageHeight
+	flips := newFlips(h.p, h.c.events, turn)
+	for y := 0; y < height; y++ {
+		resultY := ((y-offset)%height + height) % height
+		for x := 0; x < width; x++ {
+			resultX := ((x-offset)%width + width) % width
+			var cell uint8
+			if cellAt(result, resultX, resultY) {
+				cell = 255
+			}
+			if cell != h.cells[y][x] {
+				h.cells[y][x] = cell
+				flips.flip(x, y)
+			}
+		}
+	}
+	flips.send()
+}
+
+func (h *hashLife) nextTurn(turn int) error {
+	h.leap(turn, 1)
+	return nil
+}
+
+func (h *hashLife) world() ([][]uint8, error) {
+	world := makeMatrix(h.p.ImageHeight, h.p.ImageWidth)
+	for y := range h.cells {
+		copy(world[y], h.cells[y])
+	}
+	return world, nil
+}
+
+func (h *hashLife) close() {
+}
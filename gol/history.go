@@ -0,0 +1,38 @@
+package gol
+
+// history is a ring of the boards from before the last few turns, so that a paused run can step back through them.
+// The oldest board is forgotten once the ring is full.
+type history struct {
+	boards []checkpoint
+	next   int
+	length int
+}
+
+// newHistory returns a ring that keeps up to size boards, or nil when size is 0 and nothing should be kept.
+func newHistory(size int) *history {
+	if size <= 0 {
+		return nil
+	}
+	return &history{boards: make([]checkpoint, size)}
+}
+
+// push remembers the world after the given number of turns.
+func (h *history) push(turn int, world [][]uint8) {
+	h.boards[h.next] = checkpoint{CompletedTurns: turn, World: world}
+	h.next = (h.next + 1) % len(h.boards)
+	if h.length < len(h.boards) {
+		h.length++
+	}
+}
+
+// pop returns the most recent board and forgets it, or false when there are none left.
+func (h *history) pop() (checkpoint, bool) {
+	if h == nil || h.length == 0 {
+		return checkpoint{}, false
+	}
+	h.next = (h.next - 1 + len(h.boards)) % len(h.boards)
+	h.length--
+	board := h.boards[h.next]
+	h.boards[h.next] = checkpoint{}
+	return board, true
+}
@@ -1,21 +1,28 @@
 package gol
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type ioChannels struct {
 	command <-chan ioCommand
 	idle    chan<- bool
 
-	filename <-chan string
-	output   <-chan uint8
-	input    chan<- uint8
+	filename   <-chan string
+	output     <-chan []uint8
+	input      chan<- []uint8
+	checkpoint <-chan checkpoint
+	animation  <-chan *animation
+
+	// errors sends back whether each input, output, checkpoint or animation command worked once it has finished.
+	errors chan<- error
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -29,100 +36,376 @@ type ioCommand uint8
 
 // This is a way of creating enums in Go.
 // It will evaluate to:
-//		ioOutput 	= 0
-//		ioInput 	= 1
-//		ioCheckIdle = 2
+//
+//	ioOutput 	= 0
+//	ioInput 	= 1
+//	ioCheckIdle = 2
+//	ioCheckpoint = 3
+//	ioAnimation = 4
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioCheckpoint
+	ioAnimation
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
+// writeImage receives the world one row at a time and writes it in the output format.
+func (io *ioState) writeImage() error {
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
+	width, height := io.params.ImageWidth, io.params.ImageHeight
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
+	err := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	var file *os.File
+	if err == nil {
+		file, err = os.Create(filename)
+	}
+	if err != nil {
+		io.skipRows()
+		return err
+	}
 
-	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+	switch io.params.OutputFormat {
+	case PGM, PBM, PlainPGM, PlainPBM:
+		err = writeNetpbm(file, io.params.OutputFormat, width, height, io.channels.output)
+	default:
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		err = writePattern(file, io.params.OutputFormat, width, height, io.channels.output, io.params.Rule, name)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
 	}
 
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
+
+// skipRows receives the rows of a world that cannot be written, so that the distributor is not left waiting.
+func (io *ioState) skipRows() {
 	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
+		<-io.channels.output
 	}
+}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+// netpbmMagic is the magic number at the start of each of the netpbm formats.
+var netpbmMagic = map[Format]string{
+	PGM:      "P5",
+	PBM:      "P4",
+	PlainPGM: "P2",
+	PlainPBM: "P1",
+}
+
+// writeNetpbm writes a world, received one row at a time, as a pgm image or a pbm bitmap where alive cells are black.
+// The plain formats are written as text, with at most 70 characters on a line.
+// Every row is received even when writing fails, and the first error is returned at the end.
+func writeNetpbm(file *os.File, format Format, width, height int, rows <-chan []uint8) error {
+	writer := bufio.NewWriter(file)
+	_, _ = writer.WriteString(netpbmMagic[format])
+	_, _ = writer.WriteString("\n")
+	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+	_, _ = writer.WriteString(strconv.Itoa(width))
+	_, _ = writer.WriteString(" ")
+	_, _ = writer.WriteString(strconv.Itoa(height))
+	_, _ = writer.WriteString("\n")
+	if format == PGM || format == PlainPGM {
+		_, _ = writer.WriteString(strconv.Itoa(255))
+		_, _ = writer.WriteString("\n")
 	}
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	// The bufio.Writer keeps the first error and returns it from Flush.
+	bits := make([]byte, (width+7)/8)
+	for y := 0; y < height; y++ {
+		row := <-rows
+		switch format {
+		case PlainPGM:
+			writePlainRow(writer, row, "0 ", "255 ", 17)
+		case PlainPBM:
+			writePlainRow(writer, row, "0", "1", 70)
+		case PBM:
+			for i := range bits {
+				bits[i] = 0
+			}
+			for x, cell := range row {
+				if cell == 255 {
+					bits[x/8] |= 0x80 >> uint(x%8)
+				}
+			}
+			_, _ = writer.Write(bits)
+		default:
+			_, _ = writer.Write(row)
+		}
+	}
+	return writer.Flush()
+}
 
-	fmt.Println("File", filename, "output done!")
+// writePlainRow writes a row of a plain netpbm image, starting a new line after every perLine cells and at the end of the row.
+func writePlainRow(w *bufio.Writer, row []uint8, dead, alive string, perLine int) {
+	for x, cell := range row {
+		if cell == 255 {
+			_, _ = w.WriteString(alive)
+		} else {
+			_, _ = w.WriteString(dead)
+		}
+		if (x+1)%perLine == 0 || x == len(row)-1 {
+			_ = w.WriteByte('\n')
+		}
+	}
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
+// readPgmImage opens a pbm or pgm file and sends its cells one row at a time.
+// Whether the whole file could be read is sent at the end, which may be before all of the rows when it could not.
 func (io *ioState) readPgmImage() {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+	err := io.sendPgmImage(filename)
+	io.channels.errors <- err
+	if err != nil {
+		return
+	}
+
+	fmt.Println("File", filename, "input done!")
+}
+
+// sendPgmImage reads a netpbm image, checking that it has the size of the world, and sends its rows to the distributor.
+func (io *ioState) sendPgmImage(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, err := readNetpbmHeader(reader)
+	if err != nil {
+		return err
+	}
+	if header.width != io.params.ImageWidth {
+		return fmt.Errorf("incorrect width %v, expected %v", header.width, io.params.ImageWidth)
+	}
+	if header.height != io.params.ImageHeight {
+		return fmt.Errorf("incorrect height %v, expected %v", header.height, io.params.ImageHeight)
+	}
+	return readNetpbm(reader, header, io.params.AliveThreshold, func(row []byte) {
+		io.channels.input <- row
+	})
+}
+
+// readPgmSize reads the width and height from the header of a netpbm image.
+func readPgmSize(filename string) (width, height int, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	header, err := readNetpbmHeader(bufio.NewReader(file))
+	return header.width, header.height, err
+}
 
-	fields := strings.Fields(string(data))
+// netpbmHeader is the start of a P1 or P4 pbm bitmap, or a P2 or P5 pgm greyscale image.
+// The P1 and P2 images are plain text, while P4 and P5 images are binary.
+type netpbmHeader struct {
+	magic         string
+	width, height int
+	maxval        int
+}
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+// readNetpbmHeader reads the header, leaving the reader at the first pixel.
+func readNetpbmHeader(r *bufio.Reader) (netpbmHeader, error) {
+	var header netpbmHeader
+	magic, err := readNetpbmToken(r)
+	if err != nil {
+		return header, err
+	}
+	switch magic {
+	case "P1", "P2", "P4", "P5":
+		header.magic = magic
+	default:
+		return header, errors.New("not a pbm or pgm file")
 	}
 
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	numbers := []*int{&header.width, &header.height}
+	header.maxval = 1
+	if magic == "P2" || magic == "P5" {
+		numbers = append(numbers, &header.maxval)
+	}
+	for _, number := range numbers {
+		token, err := readNetpbmToken(r)
+		if err != nil {
+			return header, err
+		}
+		if *number, err = strconv.Atoi(token); err != nil || *number < 0 {
+			return header, errors.New("bad number " + strconv.Quote(token) + " in header")
+		}
 	}
+	if header.width == 0 || header.height == 0 {
+		return header, fmt.Errorf("size %vx%v has no cells", header.width, header.height)
+	}
+	if header.maxval < 1 || header.maxval > 65535 {
+		return header, fmt.Errorf("maxval %v should be from 1 to 65535", header.maxval)
+	}
+	return header, nil
+}
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+// readNetpbmToken skips whitespace and # comments, then reads up to the next whitespace.
+// The single whitespace character after the token is read as well, as it separates the header from binary pixels.
+func readNetpbmToken(r *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if len(token) > 0 {
+				return string(token), nil
+			}
+			return "", errors.New("file ends in the middle of the header")
+		}
+		switch {
+		case b == '#' && len(token) == 0:
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", errors.New("file ends in the middle of the header")
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
 	}
+}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+// readPlainBit reads the next '0' or '1' pixel of a P1 bitmap, which may or may not be separated by whitespace.
+func readPlainBit(r *bufio.Reader) (bool, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		switch b {
+		case '0':
+			return false, nil
+		case '1':
+			return true, nil
+		case '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return false, err
+			}
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+		default:
+			return false, errors.New("bad pixel " + strconv.QuoteRune(rune(b)))
+		}
 	}
+}
 
-	image := []byte(fields[4])
+// readNetpbm reads the pixels of a pbm or pgm image after its header, passing each row to use as a new slice
+// that is 255 for alive cells and 0 for dead cells.
+// Black pixels are alive in bitmaps, and grey pixels are alive when they are at least threshold out of 255.
+func readNetpbm(r *bufio.Reader, header netpbmHeader, threshold int, use func(row []byte)) error {
+	width, height := header.width, header.height
+	if threshold <= 0 {
+		threshold = 128
+	}
+	short := fmt.Errorf("only some of the %v rows are in the file", height)
 
-	for _, b := range image {
-		io.channels.input <- b
+	var packed []byte
+	switch header.magic {
+	case "P4":
+		packed = make([]byte, (width+7)/8)
+	case "P5":
+		if header.maxval > 255 {
+			packed = make([]byte, 2*width)
+		} else {
+			packed = make([]byte, width)
+		}
 	}
 
-	fmt.Println("File", filename, "input done!")
+	for y := 0; y < height; y++ {
+		row := make([]byte, width)
+		if packed != nil {
+			if _, err := io.ReadFull(r, packed); err != nil {
+				return short
+			}
+		}
+		for x := 0; x < width; x++ {
+			var alive bool
+			switch header.magic {
+			case "P1":
+				bit, err := readPlainBit(r)
+				if err == io.EOF {
+					return short
+				} else if err != nil {
+					return err
+				}
+				alive = bit
+			case "P2":
+				token, err := readNetpbmToken(r)
+				if err != nil {
+					return short
+				}
+				value, err := strconv.Atoi(token)
+				if err != nil || value < 0 || value > header.maxval {
+					return errors.New("bad pixel " + strconv.Quote(token))
+				}
+				alive = value*255 >= threshold*header.maxval
+			case "P4":
+				alive = packed[x/8]&(0x80>>uint(x%8)) != 0
+			case "P5":
+				value := int(packed[x])
+				if header.maxval > 255 {
+					value = int(packed[2*x])<<8 | int(packed[2*x+1])
+				}
+				alive = value*255 >= threshold*header.maxval
+			}
+			if alive {
+				row[x] = 255
+			}
+		}
+		use(row)
+	}
+	return nil
+}
+
+// saveCheckpoint receives a checkpoint and writes it to a file.
+func (io *ioState) saveCheckpoint() error {
+	// Request a filename and the checkpoint from the distributor.
+	filename := <-io.channels.filename
+	cp := <-io.channels.checkpoint
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeCheckpoint(filename, cp); err != nil {
+		return err
+	}
+
+	fmt.Println("File", filename, "checkpoint done!")
+	return nil
+}
+
+// saveAnimation receives the frames of an animation and writes them to a file.
+func (io *ioState) saveAnimation() error {
+	// Request a filename and the animation from the distributor.
+	filename := <-io.channels.filename
+	a := <-io.channels.animation
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeAnimation(filename, a); err != nil {
+		return err
+	}
+
+	fmt.Println("File", filename, "animation done!")
+	return nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -135,14 +418,21 @@ func startIo(p Params, c ioChannels) {
 	for {
 		select {
 		// Block and wait for requests from the distributor
-		case command := <-io.channels.command:
+		case command, ok := <-io.channels.command:
+			if !ok {
+				return
+			}
 			switch command {
 			case ioInput:
 				io.readPgmImage()
 			case ioOutput:
-				io.writePgmImage()
+				io.channels.errors <- io.writeImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
+			case ioCheckpoint:
+				io.channels.errors <- io.saveCheckpoint()
+			case ioAnimation:
+				io.channels.errors <- io.saveAnimation()
 			}
 		}
 	}
@@ -0,0 +1,117 @@
+package gol
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultInputDirectory  = "images"
+	defaultInputName       = "{w}x{h}.pgm"
+	defaultOutputDirectory = "out"
+	defaultOutputName      = "{w}x{h}x{turns}"
+	checkpointName         = "{w}x{h}.checkpoint"
+	animationName          = "{w}x{h}x{turn}.gif"
+)
+
+// expandPath replaces {w}, {h}, {turn} and {turns} in a filename template
+// with the width, height, completed turns and total turns of the run.
+func expandPath(template string, p Params, turn int) string {
+	return strings.NewReplacer(
+		"{w}", strconv.Itoa(p.ImageWidth),
+		"{h}", strconv.Itoa(p.ImageHeight),
+		"{turn}", strconv.Itoa(turn),
+		"{turns}", strconv.Itoa(p.Turns),
+	).Replace(template)
+}
+
+// isDirectory reports whether path names a directory, either because it ends with a separator or because it already is one.
+func isDirectory(path string) bool {
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator)) {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// inputTemplate returns the filename template of the image to load.
+func inputTemplate(p Params) string {
+	switch {
+	case p.Input == "":
+		return filepath.Join(defaultInputDirectory, defaultInputName)
+	case isDirectory(p.Input):
+		return filepath.Join(p.Input, defaultInputName)
+	default:
+		return p.Input
+	}
+}
+
+// inputPath returns the path of the image to load.
+func inputPath(p Params) string {
+	return expandPath(inputTemplate(p), p, 0)
+}
+
+// outputPath returns the path to write the world to after the given number of turns.
+// The extension of the output format is added when the template has none.
+func outputPath(p Params, turn int) string {
+	template := p.Output
+	switch {
+	case template == "":
+		template = filepath.Join(defaultOutputDirectory, defaultOutputName)
+	case isDirectory(template):
+		template = filepath.Join(template, defaultOutputName)
+	}
+	if filepath.Ext(template) == "" {
+		template += p.OutputFormat.Extension()
+	}
+	return expandPath(template, p, turn)
+}
+
+// checkpointPath returns the path of the checkpoint, which is kept next to the output images.
+// There is a single checkpoint for each size of world, which is replaced every time.
+func checkpointPath(p Params) string {
+	return filepath.Join(filepath.Dir(outputPath(p, 0)), expandPath(checkpointName, p, 0))
+}
+
+// animationPath returns the path to write an animation to, whose last frame is after the given number of turns.
+// Animations started with 'g' are kept next to the output images when Animation is not set.
+func animationPath(p Params, turn int) string {
+	template := p.Animation
+	if template == "" {
+		template = filepath.Join(filepath.Dir(outputPath(p, 0)), animationName)
+	}
+	return expandPath(template, p, turn)
+}
+
+// outputFormat returns the format to write in, which is taken from the extension of the output template unless another format is given.
+func outputFormat(p Params) Format {
+	if format, ok := formatExtensions[strings.ToLower(filepath.Ext(p.Output))]; ok && p.OutputFormat == PGM && !isDirectory(p.Output) {
+		return format
+	}
+	return p.OutputFormat
+}
+
+// imageSize fills in the width and height of p from the input image when they are not given.
+func imageSize(p Params) (Params, error) {
+	if p.ImageWidth != 0 && p.ImageHeight != 0 {
+		return p, nil
+	}
+	template := inputTemplate(p)
+	if strings.Contains(template, "{w}") || strings.Contains(template, "{h}") {
+		return p, errors.New("the width and height are needed to find " + template + ", or the input should be a file")
+	}
+	width, height, err := readPgmSize(template)
+	if err != nil {
+		return p, err
+	}
+	if p.ImageWidth == 0 {
+		p.ImageWidth = width
+	}
+	if p.ImageHeight == 0 {
+		p.ImageHeight = height
+	}
+	return p, nil
+}
@@ -0,0 +1,464 @@
+package gol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Format is a file format that worlds and patterns can be read from and written to.
+type Format int
+
+const (
+	// PGM is a binary greyscale image where alive cells are 255 and dead cells are 0.
+	PGM Format = iota
+	// RLE is the run length encoded format used by most pattern collections.
+	RLE
+	// Life106 lists the coordinates of every alive cell.
+	Life106
+	// Plaintext draws the cells with '.' for dead and 'O' for alive, usually in .cells files.
+	Plaintext
+	// PBM is a binary bitmap image where alive cells are black.
+	PBM
+	// PlainPGM is a pgm image written as text, with a number from 0 to 255 for every cell.
+	PlainPGM
+	// PlainPBM is a pbm bitmap written as text, with a 1 for every alive cell and a 0 for every dead one.
+	PlainPBM
+)
+
+var formatNames = map[Format]string{
+	PGM:       "pgm",
+	RLE:       "rle",
+	Life106:   "life",
+	Plaintext: "cells",
+	PBM:       "pbm",
+	PlainPGM:  "plainpgm",
+	PlainPBM:  "plainpbm",
+}
+
+var formatExtensions = map[string]Format{
+	".pgm":   PGM,
+	".pbm":   PBM,
+	".rle":   RLE,
+	".lif":   Life106,
+	".life":  Life106,
+	".cells": Plaintext,
+}
+
+// ParseFormat parses one of pgm, pbm, plainpgm, plainpbm, rle, life or cells.
+func ParseFormat(name string) (Format, error) {
+	for format, formatName := range formatNames {
+		if strings.EqualFold(name, formatName) {
+			return format, nil
+		}
+	}
+	return PGM, errors.New("unknown format " + strconv.Quote(name) + ", should be one of pgm, pbm, plainpgm, plainpbm, rle, life or cells")
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "Incorrect Format"
+}
+
+// Set allows a Format to be used as a command line flag.
+func (f *Format) Set(name string) error {
+	format, err := ParseFormat(name)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// Extension returns the file extension used when writing the format.
+func (f Format) Extension() string {
+	switch f {
+	case Life106:
+		return ".lif"
+	case PlainPGM:
+		return ".pgm"
+	case PlainPBM:
+		return ".pbm"
+	}
+	return "." + f.String()
+}
+
+// detectFormat works out the format of a file from its extension, or from its first line when the extension is unknown.
+func detectFormat(filename string, data []byte) Format {
+	if format, ok := formatExtensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return format
+	}
+	firstLine := string(data)
+	if newline := strings.IndexByte(firstLine, '\n'); newline >= 0 {
+		firstLine = firstLine[:newline]
+	}
+	firstLine = strings.TrimSpace(firstLine)
+	switch {
+	case len(firstLine) >= 2 && firstLine[0] == 'P' && strings.ContainsRune("1245", rune(firstLine[1])):
+		return PGM
+	case strings.HasPrefix(firstLine, "#Life 1.06"):
+		return Life106
+	case strings.HasPrefix(firstLine, "!"), strings.HasPrefix(firstLine, "."), strings.HasPrefix(firstLine, "O"):
+		return Plaintext
+	default:
+		return RLE
+	}
+}
+
+// pattern is a rectangle of cells read from a pattern file.
+// HasRule is set when the file gives a rule.
+type pattern struct {
+	Width, Height int
+	Alive         []util.Cell
+	Rule          Rule
+	HasRule       bool
+}
+
+// readPattern reads a pattern file, working out its format from its extension or contents.
+// The errors leave out the filename, which the IOError they are sent in already has.
+func readPattern(filename string, threshold int) (pattern, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return pattern{}, err
+	}
+	return parsePattern(filename, data, threshold)
+}
+
+// parsePattern reads a pattern in any of the supported formats.
+// Cells in netpbm images are alive when they are at least as bright as the threshold.
+func parsePattern(filename string, data []byte, threshold int) (pattern, error) {
+	switch detectFormat(filename, data) {
+	case PGM, PBM:
+		return parseNetpbmPattern(data, threshold)
+	case Life106:
+		return parseLife106(data)
+	case Plaintext:
+		return parsePlaintext(data)
+	default:
+		return parseRLE(data)
+	}
+}
+
+// parseRLE reads a header such as "x = 3, y = 3, rule = B3/S23" followed by runs of 'b' (dead) and 'o' (alive)
+// cells, with '$' ending a row and '!' ending the pattern. Lines starting with '#' are comments.
+func parseRLE(data []byte) (pattern, error) {
+	var pat pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+
+	headerFound := false
+	x, y, count := 0, 0, 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !headerFound {
+			if err := parseRLEHeader(line, &pat); err != nil {
+				return pat, err
+			}
+			headerFound = true
+			continue
+		}
+		for _, char := range line {
+			switch {
+			case char >= '0' && char <= '9':
+				count = count*10 + int(char-'0')
+			case char == ' ' || char == '\t':
+			case char == '!':
+				return pat, checkPatternBounds(pat)
+			default:
+				run := count
+				if run == 0 {
+					run = 1
+				}
+				count = 0
+				switch char {
+				case '$':
+					x, y = 0, y+run
+				case 'b', '.':
+					x += run
+				default:
+					for i := 0; i < run; i++ {
+						pat.Alive = append(pat.Alive, util.Cell{X: x + i, Y: y})
+					}
+					x += run
+				}
+			}
+		}
+	}
+	if !headerFound {
+		return pat, errors.New("RLE pattern has no header line")
+	}
+	return pat, checkPatternBounds(pat)
+}
+
+// parseRLEHeader reads the size and rule from the header line of an RLE pattern.
+// The rule runs to the end of the line, as Golly can follow it with a bounded grid such as B3/S23:T20,20.
+// The grid is left out, because the size and topology of the world are taken from the parameters.
+func parseRLEHeader(line string, pat *pattern) error {
+	fields := strings.Split(line, ",")
+	for i, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return errors.New("RLE header " + strconv.Quote(line) + " should look like x = 3, y = 3, rule = B3/S23")
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "x":
+			pat.Width, err = strconv.Atoi(value)
+		case "y":
+			pat.Height, err = strconv.Atoi(value)
+		case "rule":
+			value = strings.TrimSpace(strings.Join(append([]string{parts[1]}, fields[i+1:]...), ","))
+			if colon := strings.IndexByte(value, ':'); colon >= 0 {
+				value = value[:colon]
+			}
+			pat.Rule, err = ParseRule(value)
+			pat.HasRule = true
+		}
+		if err != nil {
+			return errors.New("RLE header " + strconv.Quote(line) + ": " + err.Error())
+		}
+		if key == "rule" {
+			break
+		}
+	}
+	if pat.Width <= 0 || pat.Height <= 0 {
+		return errors.New("RLE header " + strconv.Quote(line) + " should give a width and height of at least 1")
+	}
+	return nil
+}
+
+// checkPatternBounds makes sure every alive cell lies inside the size given in the pattern's header.
+func checkPatternBounds(pat pattern) error {
+	for _, cell := range pat.Alive {
+		if cell.X < 0 || cell.Y < 0 || cell.X >= pat.Width || cell.Y >= pat.Height {
+			return fmt.Errorf("alive cell at (%v, %v) is outside the %vx%v pattern", cell.X, cell.Y, pat.Width, pat.Height)
+		}
+	}
+	return nil
+}
+
+// parseLife106 reads the coordinates of alive cells, one cell per line, after a "#Life 1.06" line.
+// Negative coordinates are allowed, in which case the pattern is moved so that they start at 0.
+func parseLife106(data []byte) (pattern, error) {
+	var pat pattern
+	var cells []util.Cell
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return pat, errors.New("Life 1.06 line " + strconv.Quote(line) + " should have two coordinates")
+		}
+		x, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return pat, err
+		}
+		y, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return pat, err
+		}
+		cells = append(cells, util.Cell{X: x, Y: y})
+	}
+	minX, minY, maxX, maxY := 0, 0, 0, 0
+	for _, cell := range cells {
+		minX, maxX = minInt(minX, cell.X), maxInt(maxX, cell.X)
+		minY, maxY = minInt(minY, cell.Y), maxInt(maxY, cell.Y)
+	}
+	for _, cell := range cells {
+		pat.Alive = append(pat.Alive, util.Cell{X: cell.X - minX, Y: cell.Y - minY})
+	}
+	pat.Width, pat.Height = maxX-minX+1, maxY-minY+1
+	return pat, nil
+}
+
+// parsePlaintext reads rows of '.' (dead) and 'O' (alive) cells. Lines starting with '!' are comments.
+func parsePlaintext(data []byte) (pattern, error) {
+	var pat pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		for x, char := range []byte(line) {
+			switch char {
+			case 'O', '*':
+				pat.Alive = append(pat.Alive, util.Cell{X: x, Y: pat.Height})
+			case '.':
+			default:
+				return pat, errors.New("plaintext line " + strconv.Quote(line) + " should only have '.' and 'O' cells")
+			}
+		}
+		pat.Width = maxInt(pat.Width, len(line))
+		pat.Height++
+	}
+	if pat.Width == 0 {
+		return pat, errors.New("plaintext pattern has no cells")
+	}
+	return pat, nil
+}
+
+// parseNetpbmPattern reads any netpbm image as a pattern.
+func parseNetpbmPattern(data []byte, threshold int) (pattern, error) {
+	var pat pattern
+	reader := bufio.NewReader(bytes.NewReader(data))
+	header, err := readNetpbmHeader(reader)
+	if err != nil {
+		return pat, err
+	}
+	pat.Width, pat.Height = header.width, header.height
+	y := 0
+	err = readNetpbm(reader, header, threshold, func(row []byte) {
+		for x, cell := range row {
+			if cell == 255 {
+				pat.Alive = append(pat.Alive, util.Cell{X: x, Y: y})
+			}
+		}
+		y++
+	})
+	return pat, err
+}
+
+// writePattern writes a world, received one row at a time, in one of the text pattern formats.
+// Every row is received even when writing fails, and the first error is returned at the end.
+func writePattern(w io.Writer, format Format, width, height int, rows <-chan []uint8, rule Rule, name string) error {
+	writer := bufio.NewWriter(w)
+	switch format {
+	case RLE:
+		writeRLE(writer, width, height, rows, rule, name)
+	case Life106:
+		writeLife106(writer, height, rows)
+	case Plaintext:
+		writePlaintext(writer, height, rows, name)
+	default:
+		for y := 0; y < height; y++ {
+			<-rows
+		}
+		return errors.New("cannot write " + format.String() + " as a pattern")
+	}
+	return writer.Flush()
+}
+
+func writeRLE(w *bufio.Writer, width, height int, rows <-chan []uint8, rule Rule, name string) {
+	fmt.Fprintf(w, "#N %v\n", name)
+	fmt.Fprintf(w, "x = %v, y = %v, rule = %v\n", width, height, rule)
+
+	// Lines are wrapped at 70 characters without splitting a run.
+	lineLength := 0
+	addRun := func(count int, tag byte) {
+		run := string(tag)
+		if count == 0 {
+			return
+		} else if count > 1 {
+			run = strconv.Itoa(count) + run
+		}
+		if lineLength+len(run) > 70 {
+			w.WriteString("\n")
+			lineLength = 0
+		}
+		w.WriteString(run)
+		lineLength += len(run)
+	}
+
+	// Row ends are only written before the next row with alive cells, so trailing dead rows are left out.
+	rowEnds := 0
+	for y := 0; y < height; y++ {
+		row := <-rows
+		end := len(row)
+		for end > 0 && row[end-1] != 255 {
+			end--
+		}
+		if end == 0 {
+			rowEnds++
+			continue
+		}
+		addRun(rowEnds, '$')
+		rowEnds = 1
+		for x := 0; x < end; {
+			start := x
+			for x < end && (row[x] == 255) == (row[start] == 255) {
+				x++
+			}
+			if row[start] == 255 {
+				addRun(x-start, 'o')
+			} else {
+				addRun(x-start, 'b')
+			}
+		}
+	}
+	addRun(1, '!')
+	w.WriteString("\n")
+}
+
+func writeLife106(w *bufio.Writer, height int, rows <-chan []uint8) {
+	w.WriteString("#Life 1.06\n")
+	for y := 0; y < height; y++ {
+		for x, cell := range <-rows {
+			if cell == 255 {
+				fmt.Fprintf(w, "%v %v\n", x, y)
+			}
+		}
+	}
+}
+
+func writePlaintext(w *bufio.Writer, height int, rows <-chan []uint8, name string) {
+	fmt.Fprintf(w, "!Name: %v\n", name)
+	for y := 0; y < height; y++ {
+		for _, cell := range <-rows {
+			if cell == 255 {
+				w.WriteByte('O')
+			} else {
+				w.WriteByte('.')
+			}
+		}
+		w.WriteByte('\n')
+	}
+}
+
+// placePattern puts a pattern into an empty world, either centred or with its top left corner at (x, y).
+func placePattern(p Params, pat pattern) ([][]uint8, error) {
+	x, y := p.PatternX, p.PatternY
+	if p.CentrePattern {
+		x, y = (p.ImageWidth-pat.Width)/2, (p.ImageHeight-pat.Height)/2
+	}
+	if x < 0 || y < 0 || x+pat.Width > p.ImageWidth || y+pat.Height > p.ImageHeight {
+		return nil, fmt.Errorf("%vx%v pattern at (%v, %v) does not fit in the %vx%v world",
+			pat.Width, pat.Height, x, y, p.ImageWidth, p.ImageHeight)
+	}
+	world := makeMatrix(p.ImageHeight, p.ImageWidth)
+	for _, cell := range pat.Alive {
+		world[y+cell.Y][x+cell.X] = 255
+	}
+	return world, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
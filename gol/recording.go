@@ -0,0 +1,541 @@
+package gol
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// recordingHeader starts every recording, followed by the width and height and then a gzipped stream of records.
+// Each record is a tag byte followed by varints, and the cells in a record are sorted and stored as the gaps between them.
+// The first record is the initial board, and the records of every turn follow it.
+const recordingHeader = "GOL RECORDING 2\n"
+
+// Tags of the records in a recording.
+const (
+	recordFlips byte = iota + 1
+	recordTurn
+	recordState
+	recordAliveCount
+	recordStats
+	recordImage
+	recordCheckpoint
+	recordIOError
+	recordWorkersLost
+	recordFinal
+	recordBoard
+)
+
+// Record writes every event it receives to w as a recording of a run of p, until events is closed.
+// The cells flipped before the first other event, which is the StateChange sent once the run is executing,
+// are recorded as the initial board. After that, the CellFlipped and CellsFlipped events between two other events are recorded together as one diff.
+func Record(w io.Writer, p Params, events <-chan Event) error {
+	r := &recordWriter{width: p.ImageWidth}
+	if _, err := io.WriteString(w, recordingHeader); err != nil {
+		for range events {
+		}
+		return err
+	}
+	r.putInt(p.ImageWidth, w)
+	r.putInt(p.ImageHeight, w)
+	compressor := gzip.NewWriter(w)
+	r.w = bufio.NewWriter(compressor)
+
+	var flips []util.Cell
+	flipsTurn := 0
+	started := false
+	for event := range events {
+		switch event := event.(type) {
+		case CellFlipped:
+			if len(flips) == 0 {
+				flipsTurn = event.CompletedTurns
+			}
+			flips = append(flips, event.Cell)
+			continue
+		case CellsFlipped:
+			if len(flips) == 0 {
+				flipsTurn = event.CompletedTurns
+			}
+			flips = append(flips, event.Cells...)
+			continue
+		}
+		if !started {
+			if len(flips) == 0 {
+				flipsTurn = event.GetCompletedTurns()
+			}
+			r.cellsRecord(recordBoard, flipsTurn, flips)
+			started = true
+			flips = nil
+		} else if len(flips) > 0 {
+			r.cellsRecord(recordFlips, flipsTurn, flips)
+			flips = nil
+		}
+		r.event(event)
+	}
+	if !started {
+		r.cellsRecord(recordBoard, flipsTurn, flips)
+	} else if len(flips) > 0 {
+		r.cellsRecord(recordFlips, flipsTurn, flips)
+	}
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := compressor.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// recordWriter writes the records of a recording, remembering the first error.
+type recordWriter struct {
+	w     *bufio.Writer
+	width int
+	err   error
+}
+
+func (r *recordWriter) event(event Event) {
+	switch event := event.(type) {
+	case TurnComplete:
+		r.tag(recordTurn, event.CompletedTurns)
+	case StateChange:
+		r.tag(recordState, event.CompletedTurns)
+		r.int(int(event.NewState))
+	case AliveCellsCount:
+		r.tag(recordAliveCount, event.CompletedTurns)
+		r.int(event.CellsCount)
+		r.float(event.TurnsPerSecond)
+	case StatsReport:
+		r.tag(recordStats, event.CompletedTurns)
+		for _, n := range []int{event.Alive, event.Births, event.Deaths, event.Min.X, event.Min.Y, event.Max.X, event.Max.Y} {
+			r.int(n)
+		}
+		r.float(event.TurnsPerSecond)
+	case ImageOutputComplete:
+		r.tag(recordImage, event.CompletedTurns)
+		r.string(event.Filename)
+	case CheckpointOutputComplete:
+		r.tag(recordCheckpoint, event.CompletedTurns)
+		r.string(event.Filename)
+	case IOError:
+		r.tag(recordIOError, event.CompletedTurns)
+		r.string(event.Filename)
+		r.string(event.Err.Error())
+	case WorkersLost:
+		r.tag(recordWorkersLost, event.CompletedTurns)
+		r.int(event.Workers)
+		r.int(len(event.Lost))
+		for _, address := range event.Lost {
+			r.string(address)
+		}
+	case FinalTurnComplete:
+		r.tag(recordFinal, event.CompletedTurns)
+		r.cells(event.Alive)
+	}
+}
+
+// cellsRecord writes the initial board or the diff of a turn.
+func (r *recordWriter) cellsRecord(tag byte, turn int, cells []util.Cell) {
+	r.tag(tag, turn)
+	r.cells(cells)
+}
+
+func (r *recordWriter) tag(tag byte, turn int) {
+	if r.err == nil {
+		r.err = r.w.WriteByte(tag)
+	}
+	r.int(turn)
+}
+
+func (r *recordWriter) int(n int) {
+	r.putInt(n, r.w)
+}
+
+func (r *recordWriter) putInt(n int, w io.Writer) {
+	if r.err == nil {
+		var buf [binary.MaxVarintLen64]byte
+		_, r.err = w.Write(buf[:binary.PutVarint(buf[:], int64(n))])
+	}
+}
+
+func (r *recordWriter) float(f float64) {
+	r.int(int(math.Float64bits(f)))
+}
+
+func (r *recordWriter) string(s string) {
+	r.int(len(s))
+	if r.err == nil {
+		_, r.err = r.w.WriteString(s)
+	}
+}
+
+// cells writes how many cells there are, then the gaps between them in the order they come in row by row.
+// A cell that is listed twice is kept twice, so that it is flipped twice when replayed.
+func (r *recordWriter) cells(cells []util.Cell) {
+	indexes := make([]int, len(cells))
+	for i, cell := range cells {
+		indexes[i] = cell.Y*r.width + cell.X
+	}
+	sort.Ints(indexes)
+	r.int(len(indexes))
+	previous := 0
+	for _, index := range indexes {
+		r.int(index - previous)
+		previous = index
+	}
+}
+
+// Recording is a run read back from a recording, which can be replayed without working out any turns.
+// The turns are read from the recording as they are replayed, so the reader must be kept open until the replay has finished.
+type Recording struct {
+	Width, Height int
+
+	// board holds the cells alive in the initial board, after turn turns.
+	turn  int
+	board []util.Cell
+	r     *recordReader
+}
+
+// ReadRecording reads the size and initial board of a recording written by Record.
+func ReadRecording(reader io.Reader) (*Recording, error) {
+	buffered := bufio.NewReader(reader)
+	header := make([]byte, len(recordingHeader))
+	if _, err := io.ReadFull(buffered, header); err != nil || string(header) != recordingHeader {
+		return nil, errors.New("not a recording")
+	}
+	r := &recordReader{r: buffered}
+	rec := &Recording{Width: r.int(), Height: r.int()}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if rec.Width <= 0 || rec.Height <= 0 {
+		return nil, errors.New("recording has no size")
+	}
+	decompressor, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	rec.r = &recordReader{r: bufio.NewReader(decompressor), width: rec.Width, height: rec.Height}
+
+	tag, err := rec.r.r.ReadByte()
+	if err != nil || tag != recordBoard {
+		return nil, errors.New("recording has no initial board")
+	}
+	rec.turn = rec.r.int()
+	rec.board = rec.r.cells()
+	if rec.r.err != nil {
+		return nil, rec.r.err
+	}
+	return rec, nil
+}
+
+// next reads the next event from the recording, returning nil at the end of it.
+func (rec *Recording) next() (Event, error) {
+	tag, err := rec.r.r.ReadByte()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	event := rec.r.event(tag)
+	if rec.r.err != nil {
+		return nil, rec.r.err
+	}
+	return event, nil
+}
+
+// recordReader reads the records of a recording, remembering the first error.
+type recordReader struct {
+	r             *bufio.Reader
+	width, height int
+	err           error
+}
+
+func (r *recordReader) event(tag byte) Event {
+	turn := r.int()
+	switch tag {
+	case recordFlips:
+		return CellsFlipped{turn, r.cells()}
+	case recordTurn:
+		return TurnComplete{turn}
+	case recordState:
+		return StateChange{turn, State(r.int())}
+	case recordAliveCount:
+		return AliveCellsCount{turn, r.int(), r.float()}
+	case recordStats:
+		stats := StatsReport{CompletedTurns: turn, Alive: r.int(), Births: r.int(), Deaths: r.int()}
+		stats.Min = util.Cell{X: r.int(), Y: r.int()}
+		stats.Max = util.Cell{X: r.int(), Y: r.int()}
+		stats.TurnsPerSecond = r.float()
+		return stats
+	case recordImage:
+		return ImageOutputComplete{turn, r.string()}
+	case recordCheckpoint:
+		return CheckpointOutputComplete{turn, r.string()}
+	case recordIOError:
+		return IOError{turn, r.string(), errors.New(r.string())}
+	case recordWorkersLost:
+		lost := WorkersLost{CompletedTurns: turn, Workers: r.int()}
+		for n := r.int(); n > 0 && r.err == nil; n-- {
+			lost.Lost = append(lost.Lost, r.string())
+		}
+		return lost
+	case recordFinal:
+		return FinalTurnComplete{turn, r.cells()}
+	}
+	r.fail(errors.New("recording has an unknown record"))
+	return nil
+}
+
+func (r *recordReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *recordReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(r.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	r.fail(err)
+	return int(n)
+}
+
+func (r *recordReader) float() float64 {
+	return math.Float64frombits(uint64(r.int()))
+}
+
+func (r *recordReader) string() string {
+	n := r.int()
+	if r.err != nil || n < 0 || n > 1<<20 {
+		r.fail(errors.New("recording has a broken string"))
+		return ""
+	}
+	s := make([]byte, n)
+	_, err := io.ReadFull(r.r, s)
+	r.fail(err)
+	return string(s)
+}
+
+func (r *recordReader) cells() []util.Cell {
+	n := r.int()
+	if r.err != nil || n < 0 || n > 8*r.width*r.height {
+		r.fail(errors.New("recording has a broken list of cells"))
+		return nil
+	}
+	cells := make([]util.Cell, 0, n)
+	index := 0
+	for i := 0; i < n && r.err == nil; i++ {
+		index += r.int()
+		if index < 0 || index >= r.width*r.height {
+			r.fail(errors.New("recording has a cell outside the board"))
+			break
+		}
+		cells = append(cells, util.Cell{X: index % r.width, Y: index / r.width})
+	}
+	return cells
+}
+
+// replayedTurn holds the events of a turn that has been replayed, from the turn before it up to and including its TurnComplete,
+// so that its flips can be sent again to undo it and its events sent again to redo it.
+type replayedTurn struct {
+	from   int
+	events []Event
+}
+
+// Replay sends the recorded events to events, no faster than p.TurnsPerSecond, and closes it at the end. The replay is controlled by commands like a run:
+// it can be paused, resumed, sped up, slowed down and quit, stepped forward, and stepped back through the last p.History turns, as far as the initial board.
+// Save and Detach are acknowledged with an error, because there is nothing to save or detach from.
+// An error is returned when the rest of the recording cannot be read.
+func (rec *Recording) Replay(p Params, events chan<- Event, commands <-chan Command) error {
+	defer close(events)
+
+	turn := rec.turn
+	if len(rec.board) > 0 {
+		events <- CellsFlipped{turn, rec.board}
+	}
+	// undone holds the turns that can be stepped back through, and redo the turns that have been stepped back through,
+	// which are replayed from there instead of from the recording.
+	var undone, redo []replayedTurn
+	finished := false
+
+	speed := p.TurnsPerSecond
+	var throttle <-chan time.Time
+	var pacing pace
+	paused := false
+	setPaused := func(pause bool) {
+		if pause != paused {
+			paused = pause
+			pacing.reset()
+			if paused {
+				events <- StateChange{turn, Paused}
+			} else {
+				events <- StateChange{turn, Executing}
+			}
+		}
+	}
+	stepsLeft := 0
+	var steps []Command
+
+	acknowledge := func(command Command, err error) {
+		if reply := command.reply(); reply != nil {
+			reply <- Ack{turn, err}
+		}
+	}
+
+	// keep remembers a turn that has been replayed, forgetting the oldest once more than p.History are kept.
+	keep := func(replayed replayedTurn) {
+		if p.History <= 0 {
+			return
+		}
+		undone = append(undone, replayed)
+		if len(undone) > p.History {
+			undone = append(undone[:0], undone[1:]...)
+		}
+	}
+
+	// replayTurn sends the events of the next turn, returning false once the recording has finished.
+	replayTurn := func() (bool, error) {
+		if len(redo) > 0 {
+			replayed := redo[len(redo)-1]
+			redo = redo[:len(redo)-1]
+			for _, event := range replayed.events {
+				events <- event
+			}
+			turn = replayed.events[len(replayed.events)-1].GetCompletedTurns()
+			keep(replayed)
+			return true, nil
+		}
+		replayed := replayedTurn{from: turn}
+		for {
+			event, err := rec.next()
+			if event == nil || err != nil {
+				return false, err
+			}
+			events <- event
+			replayed.events = append(replayed.events, event)
+			if _, ok := event.(TurnComplete); ok {
+				turn = event.GetCompletedTurns()
+				keep(replayed)
+				return true, nil
+			}
+		}
+	}
+
+	// apply applies a command between turns, returning true when the replay should stop.
+	apply := func(command Command) bool {
+		switch command := command.(type) {
+		case Quit, Shutdown:
+			events <- StateChange{turn, Quitting}
+			acknowledge(command, nil)
+			return true
+		case Pause:
+			setPaused(true)
+			acknowledge(command, nil)
+		case Resume:
+			setPaused(false)
+			acknowledge(command, nil)
+		case TogglePause:
+			setPaused(!paused)
+			acknowledge(command, nil)
+		case Step:
+			turns := command.Turns
+			if turns == 0 {
+				turns = p.StepTurns
+				if turns == 0 {
+					turns = 10
+				}
+			}
+			if turns < 0 {
+				acknowledge(command, nil)
+			} else {
+				stepsLeft += turns
+				steps = append(steps, command)
+			}
+		case StepBack:
+			setPaused(true)
+			if len(undone) == 0 {
+				acknowledge(command, errors.New("no earlier turn to step back to"))
+				break
+			}
+			replayed := undone[len(undone)-1]
+			undone = undone[:len(undone)-1]
+			for _, event := range replayed.events {
+				if flips, ok := event.(CellsFlipped); ok {
+					events <- flips
+				}
+			}
+			redo = append(redo, replayed)
+			turn = replayed.from
+			events <- TurnComplete{turn}
+			acknowledge(command, nil)
+		case SetSpeed:
+			speed = command.TurnsPerSecond
+			throttle = nil
+			pacing.reset()
+			acknowledge(command, nil)
+		case ChangeSpeed:
+			speed = changeSpeed(speed, command.Steps)
+			throttle = nil
+			pacing.reset()
+			acknowledge(command, nil)
+		default:
+			acknowledge(command, errors.New("not possible while replaying"))
+		}
+		return false
+	}
+
+	running := make(chan struct{})
+	close(running)
+	var err error
+	for !finished {
+		var turnReady <-chan struct{}
+		if (!paused || stepsLeft > 0) && throttle == nil {
+			turnReady = running
+		}
+
+		select {
+		case command := <-commands:
+			if apply(command) {
+				return nil
+			}
+		case <-throttle:
+			throttle = nil
+		case <-turnReady:
+			var replayed bool
+			replayed, err = replayTurn()
+			if !replayed {
+				finished = true
+				break
+			}
+			if stepsLeft > 0 {
+				stepsLeft--
+				if stepsLeft == 0 {
+					for _, step := range steps {
+						acknowledge(step, nil)
+					}
+					steps = nil
+				}
+			}
+			if speed > 0 {
+				throttle = pacing.after(1, speed)
+			}
+		}
+	}
+	for _, step := range steps {
+		acknowledge(step, nil)
+	}
+	return err
+}
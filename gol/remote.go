@@ -0,0 +1,82 @@
+package gol
+
+import (
+	"net/rpc"
+)
+
+// remote is the engine that processes turns on a broker, connected to from the local controller.
+type remote struct {
+	client *rpc.Client
+	p      Params
+	c      distributorChannels
+}
+
+// dialBroker connects to the broker in p.Broker and hands it the initial world,
+// unless the controller is attaching to the run that the broker already has.
+func dialBroker(p Params, c distributorChannels, turn int, world [][]uint8) (*remote, error) {
+	client, err := rpc.Dial("tcp", p.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Attach {
+		err = client.Call(BrokerStart, StartRequest{Params: p, CompletedTurns: turn, World: world}, new(StartResponse))
+		if err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
+	return &remote{client: client, p: p, c: c}, nil
+}
+
+// attachBroker takes over the run on the broker in address from a controller that detached,
+// returning it like a checkpoint saved at the turn the broker has got to.
+func attachBroker(address string) (checkpoint, error) {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return checkpoint{}, err
+	}
+	defer client.Close()
+	res := new(AttachResponse)
+	if err := client.Call(BrokerAttach, AttachRequest{}, res); err != nil {
+		return checkpoint{}, err
+	}
+	return checkpoint{Params: res.Params, CompletedTurns: res.CompletedTurns, World: res.World}, nil
+}
+
+func (r *remote) nextTurn(turn int) error {
+	res := new(TurnResponse)
+	if err := r.client.Call(BrokerNextTurn, TurnRequest{SkipFlipped: !r.p.sends(FlipEvents)}, res); err != nil {
+		return err
+	}
+	if len(res.Lost) > 0 {
+		r.c.events <- WorkersLost{CompletedTurns: turn, Lost: res.Lost, Workers: res.Workers}
+	}
+	flips := newFlips(r.p, r.c.events, turn)
+	for _, cell := range res.Flipped {
+		flips.flip(cell.X, cell.Y)
+	}
+	flips.send()
+	return nil
+}
+
+func (r *remote) world() ([][]uint8, error) {
+	res := new(WorldResponse)
+	if err := r.client.Call(BrokerWorld, WorldRequest{}, res); err != nil {
+		return nil, err
+	}
+	return res.World, nil
+}
+
+// shutdown tells the broker to shut down its worker servers and itself.
+func (r *remote) shutdown() error {
+	return ignoreShutdownError(r.client.Call(BrokerShutdown, ShutdownRequest{}, new(ShutdownResponse)))
+}
+
+// detach leaves the broker processing the rest of the turns on its own.
+func (r *remote) detach() error {
+	return r.client.Call(BrokerDetach, DetachRequest{}, new(DetachResponse))
+}
+
+func (r *remote) close() {
+	_ = r.client.Close()
+}
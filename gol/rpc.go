@@ -0,0 +1,106 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Names of the methods registered by the broker and worker servers.
+var (
+	BrokerStart    = "Broker.Start"
+	BrokerNextTurn = "Broker.NextTurn"
+	BrokerWorld    = "Broker.World"
+	WorkerInit     = "WorkerServer.Init"
+	WorkerStep     = "WorkerServer.Step"
+	WorkerRows     = "WorkerServer.Rows"
+	BrokerShutdown = "Broker.Shutdown"
+	BrokerDetach   = "Broker.Detach"
+	BrokerAttach   = "Broker.Attach"
+	WorkerShutdown = "WorkerServer.Shutdown"
+)
+
+// StartRequest hands the initial world to the broker, along with the turns already completed when carrying on from a checkpoint.
+type StartRequest struct {
+	Params         Params
+	CompletedTurns int
+	World          [][]uint8
+}
+
+type StartResponse struct {
+}
+
+// TurnRequest asks the broker to process one more turn. SkipFlipped leaves the cells that changed out of the response.
+type TurnRequest struct {
+	SkipFlipped bool
+}
+
+// TurnResponse lists the cells that changed while the broker processed one more turn,
+// and the workers that failed since the last turn along with how many are left.
+type TurnResponse struct {
+	CompletedTurns int
+	Flipped        []util.Cell
+	Lost           []string
+	Workers        int
+}
+
+type WorldRequest struct {
+}
+
+// WorldResponse carries the full world, put back together from every worker's strip.
+type WorldResponse struct {
+	CompletedTurns int
+	World          [][]uint8
+}
+
+// InitRequest hands a worker server the strip of the world it keeps for the rest of the run.
+type InitRequest struct {
+	Params Params
+	StartY int
+	Rows   [][]uint8
+}
+
+type InitResponse struct {
+}
+
+// StepRequest carries the halo rows a worker server needs to process one turn of its strip.
+// SkipFlipped saves the worker from keeping track of the cells that change, and leaves them out of the response.
+type StepRequest struct {
+	Above       []uint8
+	Below       []uint8
+	SkipFlipped bool
+}
+
+// StepResponse carries the new edge rows of the strip, which become the halos of the neighbouring strips.
+type StepResponse struct {
+	Top     []uint8
+	Bottom  []uint8
+	Flipped []util.Cell
+}
+
+type RowsRequest struct {
+}
+
+type RowsResponse struct {
+	Rows [][]uint8
+}
+
+// ShutdownRequest asks a broker or worker server process to exit.
+// A broker passes it on to all of its workers first.
+type ShutdownRequest struct{}
+
+type ShutdownResponse struct{}
+
+type DetachRequest struct{}
+
+// DetachResponse gives the turn that the controller detached at.
+type DetachResponse struct {
+	CompletedTurns int
+}
+
+type AttachRequest struct{}
+
+// AttachResponse gives a new controller everything it needs to carry on with the run.
+type AttachResponse struct {
+	Params         Params
+	CompletedTurns int
+	World          [][]uint8
+}
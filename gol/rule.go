@@ -0,0 +1,102 @@
+package gol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Rule is a Life-like rule in B/S notation.
+// Bit n of Birth is set when a dead cell with n alive neighbours is born,
+// and bit n of Survive is set when an alive cell with n alive neighbours stays alive.
+// The zero Rule is B/S, in which no cell is ever born or survives.
+// It stands for a rule that was not given unless Params.RuleGiven is set.
+type Rule struct {
+	Birth   uint16
+	Survive uint16
+}
+
+// Conway is the B3/S23 rule of Conway's Game of Life.
+var Conway = Rule{Birth: 1 << 3, Survive: 1<<2 | 1<<3}
+
+// ParseRule parses a rulestring such as "B36/S23" (HighLife), "B2/S" (Seeds) or "B3678/S34678" (Day & Night).
+// The older S/B notation without letters, such as "23/36", is also accepted.
+func ParseRule(rulestring string) (Rule, error) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(rulestring)), "/")
+	if len(parts) != 2 {
+		return Rule{}, errors.New("rule " + strconv.Quote(rulestring) + " should have the form B3/S23")
+	}
+
+	birth, survive := parts[0], parts[1]
+	if strings.HasPrefix(birth, "S") && strings.HasPrefix(survive, "B") {
+		birth, survive = survive, birth
+	} else if !strings.HasPrefix(birth, "B") && !strings.HasPrefix(survive, "S") {
+		// S/B notation lists the survival counts first.
+		birth, survive = "B"+survive, "S"+birth
+	}
+	if !strings.HasPrefix(birth, "B") || !strings.HasPrefix(survive, "S") {
+		return Rule{}, errors.New("rule " + strconv.Quote(rulestring) + " should have the form B3/S23")
+	}
+
+	var rule Rule
+	var err error
+	if rule.Birth, err = parseNeighbourCounts(birth[1:]); err != nil {
+		return Rule{}, errors.New("rule " + strconv.Quote(rulestring) + ": " + err.Error())
+	}
+	if rule.Survive, err = parseNeighbourCounts(survive[1:]); err != nil {
+		return Rule{}, errors.New("rule " + strconv.Quote(rulestring) + ": " + err.Error())
+	}
+	return rule, nil
+}
+
+func parseNeighbourCounts(digits string) (uint16, error) {
+	var counts uint16
+	for _, digit := range digits {
+		if digit < '0' || digit > '8' {
+			return 0, errors.New("neighbour count " + strconv.QuoteRune(digit) + " is not between 0 and 8")
+		}
+		counts |= 1 << uint(digit-'0')
+	}
+	return counts, nil
+}
+
+// nextState returns the state of a cell with n alive neighbours after one turn.
+func (r Rule) nextState(state uint8, n int) uint8 {
+	if state == 255 {
+		if r.Survive&(1<<uint(n)) != 0 {
+			return 255
+		}
+		return 0
+	}
+	if r.Birth&(1<<uint(n)) != 0 {
+		return 255
+	}
+	return 0
+}
+
+func (r Rule) String() string {
+	var rulestring strings.Builder
+	rulestring.WriteString("B")
+	for n := 0; n <= 8; n++ {
+		if r.Birth&(1<<uint(n)) != 0 {
+			rulestring.WriteString(strconv.Itoa(n))
+		}
+	}
+	rulestring.WriteString("/S")
+	for n := 0; n <= 8; n++ {
+		if r.Survive&(1<<uint(n)) != 0 {
+			rulestring.WriteString(strconv.Itoa(n))
+		}
+	}
+	return rulestring.String()
+}
+
+// Set allows a Rule to be used as a command line flag.
+func (r *Rule) Set(rulestring string) error {
+	rule, err := ParseRule(rulestring)
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
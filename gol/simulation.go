@@ -0,0 +1,148 @@
+package gol
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Board is a copy of the world, indexed by row and then column, where alive cells are 255 and dead cells are 0.
+type Board [][]uint8
+
+// Alive returns the alive cells of the board.
+func (b Board) Alive() []util.Cell {
+	var alive []util.Cell
+	for y, row := range b {
+		for x, cell := range row {
+			if cell == 255 {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return alive
+}
+
+// Result is the world once a simulation has stopped.
+type Result struct {
+	CompletedTurns int
+	Board          Board
+}
+
+// Simulation is a run of the Game of Life that can be controlled from Go code instead of with key presses.
+// Pause, Resume and Snapshot wait for the simulation to be started, and return straight away once it has stopped.
+type Simulation struct {
+	p        Params
+	events   chan<- Event
+	commands chan Command
+	done     chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	result  Result
+	err     error
+}
+
+// NewSimulation returns a simulation of p that has not been started.
+// The events are sent to events as they are by Run, and the channel is closed when the simulation stops.
+// The events are thrown away when events is nil, and only StateEvents are sent then.
+func NewSimulation(p Params, events chan<- Event) *Simulation {
+	if events == nil {
+		p.Events = StateEvents
+	}
+	return &Simulation{
+		p:        p,
+		events:   events,
+		commands: make(chan Command),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts processing the turns in the background. Cancelling the context stops the workers,
+// and Wait then returns the world as it was along with the context's error.
+func (s *Simulation) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("the simulation has already been started")
+	}
+	s.started = true
+
+	events := s.events
+	if events == nil {
+		discarded := make(chan Event, 1000)
+		go func() {
+			for range discarded {
+			}
+		}()
+		events = discarded
+	}
+	go func() {
+		result, err := run(ctx, s.p, nil, events, nil, s.commands)
+		s.mu.Lock()
+		s.result, s.err = result, err
+		s.mu.Unlock()
+		close(s.done)
+	}()
+	return nil
+}
+
+// Pause stops processing turns until Resume is called.
+func (s *Simulation) Pause() {
+	s.Apply(Pause{})
+}
+
+// Resume carries on processing turns after Pause.
+func (s *Simulation) Resume() {
+	s.Apply(Resume{})
+}
+
+// Snapshot returns a copy of the world and the number of turns completed so far.
+// Once the simulation has stopped it returns the final world.
+func (s *Simulation) Snapshot() (Board, int) {
+	result := make(chan Result, 1)
+	if !s.send(snapshot{result}) {
+		final, _ := s.Wait()
+		return final.Board, final.CompletedTurns
+	}
+	current := <-result
+	return current.Board, current.CompletedTurns
+}
+
+// Apply applies a command and waits for it to be acknowledged, which may be after the simulation has stopped.
+// The Reply channel of the command is ignored.
+func (s *Simulation) Apply(command Command) Ack {
+	reply := make(chan Ack, 1)
+	command = command.withReply(reply)
+	if !s.send(command) {
+		final, err := s.Wait()
+		return Ack{final.CompletedTurns, err}
+	}
+	select {
+	case ack := <-reply:
+		return ack
+	case <-s.done:
+		final, err := s.Wait()
+		return Ack{final.CompletedTurns, err}
+	}
+}
+
+// Wait waits for the simulation to stop, then returns the final world
+// along with the first file that could not be read or written, or the context's error.
+func (s *Simulation) Wait() (Result, error) {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result, s.err
+}
+
+// send sends a command to the distributor, returning false when the simulation has already stopped.
+func (s *Simulation) send(command Command) bool {
+	select {
+	case s.commands <- command:
+		return true
+	case <-s.done:
+		return false
+	}
+}
@@ -0,0 +1,81 @@
+package gol
+
+import (
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// reporter works out each StatsReport, adding up the cells born and died in every turn since the last report.
+type reporter struct {
+	p     Params
+	turn  int
+	time  time.Time
+	world [][]uint8
+
+	// births and deaths are counted since the last report.
+	births, deaths int
+}
+
+// newReporter starts counting from the world after the given number of turns.
+// The world is only kept when reports are sent, as it holds on to another copy of the board.
+func newReporter(p Params, turn int, world [][]uint8) *reporter {
+	r := &reporter{p: p, turn: turn, time: time.Now()}
+	if p.sends(ReportEvents) {
+		r.world = world
+	}
+	return r
+}
+
+// count adds the cells born and died in the turns that led to world, by comparing it with the world after the turns before.
+func (r *reporter) count(world [][]uint8) {
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell != r.world[y][x] {
+				if cell == 255 {
+					r.births++
+				} else {
+					r.deaths++
+				}
+			}
+		}
+	}
+	r.world = world
+}
+
+// rebase makes world the one that the next turn is counted from, without counting the cells that changed to get to it.
+// It is used when the world changes other than by processing a turn, such as when stepping back.
+func (r *reporter) rebase(world [][]uint8) {
+	if r.world != nil {
+		r.world = world
+	}
+}
+
+// report returns the statistics of the world after the given number of turns, and starts counting again for the next report.
+func (r *reporter) report(turn int, world [][]uint8) StatsReport {
+	now := time.Now()
+	stats := StatsReport{CompletedTurns: turn, Births: r.births, Deaths: r.deaths}
+	if elapsed := now.Sub(r.time).Seconds(); elapsed > 0 {
+		stats.TurnsPerSecond = float64(turn-r.turn) / elapsed
+	}
+
+	stats.Min = util.Cell{X: r.p.ImageWidth, Y: r.p.ImageHeight}
+	stats.Max = util.Cell{X: -1, Y: -1}
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 255 {
+				stats.Alive++
+				stats.Min.X = minInt(stats.Min.X, x)
+				stats.Min.Y = minInt(stats.Min.Y, y)
+				stats.Max.X = maxInt(stats.Max.X, x)
+				stats.Max.Y = maxInt(stats.Max.Y, y)
+			}
+		}
+	}
+	if stats.Alive == 0 {
+		stats.Min, stats.Max = util.Cell{}, util.Cell{}
+	}
+
+	r.turn, r.time, r.births, r.deaths = turn, now, 0, 0
+	return stats
+}
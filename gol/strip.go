@@ -0,0 +1,159 @@
+package gol
+
+// strip is a horizontal band of the world kept by a single worker for the whole run.
+// The first and last rows of cells are the halo rows copied from the neighbouring strips.
+type strip struct {
+	p      Params
+	startY int
+	cells  [][]uint8
+	next   [][]uint8
+}
+
+func newStrip(p Params, startY int, rows [][]uint8) *strip {
+	s := &strip{
+		p:      p,
+		startY: startY,
+		cells:  makeMatrix(len(rows)+2, p.ImageWidth),
+		next:   makeMatrix(len(rows)+2, p.ImageWidth),
+	}
+	for i, row := range rows {
+		copy(s.cells[i+1], row)
+	}
+	return s
+}
+
+// top returns the first row of the strip, which is the halo below the strip above.
+func (s *strip) top() []uint8 {
+	return s.cells[1]
+}
+
+// bottom returns the last row of the strip, which is the halo above the strip below.
+func (s *strip) bottom() []uint8 {
+	return s.cells[len(s.cells)-2]
+}
+
+func (s *strip) setHalos(above, below []uint8) {
+	copy(s.cells[0], above)
+	copy(s.cells[len(s.cells)-1], below)
+}
+
+// rows returns the strip without its halo rows.
+func (s *strip) rows() [][]uint8 {
+	return s.cells[1 : len(s.cells)-1]
+}
+
+// step moves the strip on by one turn using the current halo rows.
+// flip is called with the absolute position of every cell that changed.
+func (s *strip) step(flip func(x, y int)) {
+	var absoluteFlip func(x, y int)
+	if flip != nil {
+		absoluteFlip = func(x, y int) {
+			flip(x, s.startY+y)
+		}
+	}
+	calculateNextState(s.p, s.cells, s.next, absoluteFlip)
+	s.cells, s.next = s.next, s.cells
+}
+
+// haloWorker is a goroutine that keeps its strip between turns and swaps edge rows with its neighbours.
+type haloWorker struct {
+	strip *strip
+	turns chan int
+	done  chan<- bool
+
+	// first and last are set for the workers whose halos come from across the edge of the board.
+	first, last bool
+
+	// above and below receive the halo rows from the neighbouring workers.
+	above, below     <-chan []uint8
+	toAbove, toBelow chan<- []uint8
+}
+
+func (w *haloWorker) run(c distributorChannels) {
+	for turn := range w.turns {
+		w.toAbove <- w.haloFor(w.strip.top(), w.first)
+		w.toBelow <- w.haloFor(w.strip.bottom(), w.last)
+		w.strip.setHalos(<-w.above, <-w.below)
+		var flip func(x, y int)
+		flips := newFlips(w.strip.p, c.events, turn)
+		if flips != nil {
+			flip = flips.flip
+		}
+		w.strip.step(flip)
+		flips.send()
+		w.done <- true
+	}
+}
+
+// haloFor returns the halo row that a neighbour sees when row is one of the edges of this worker's strip.
+func (w *haloWorker) haloFor(row []uint8, acrossEdge bool) []uint8 {
+	if acrossEdge {
+		return w.strip.p.Topology.acrossEdge(row)
+	}
+	return append([]uint8(nil), row...)
+}
+
+// haloWorkers is the engine that processes turns with p.Threads haloWorkers on this machine.
+type haloWorkers struct {
+	workers []*haloWorker
+	done    chan bool
+}
+
+func startHaloWorkers(p Params, c distributorChannels, world [][]uint8) *haloWorkers {
+	n := getNumberOfWorkers(p, p.Threads)
+	h := &haloWorkers{done: make(chan bool, n)}
+
+	// fromAbove[j] carries the bottom row of worker j-1 and fromBelow[j] the top row of worker j+1.
+	fromAbove := make([]chan []uint8, n)
+	fromBelow := make([]chan []uint8, n)
+	for j := 0; j < n; j++ {
+		fromAbove[j] = make(chan []uint8, 1)
+		fromBelow[j] = make(chan []uint8, 1)
+	}
+
+	for j := 0; j < n; j++ {
+		startY, endY := getWorkerBounds(p.ImageHeight, j, n)
+		w := &haloWorker{
+			strip:   newStrip(p, startY, world[startY:endY]),
+			turns:   make(chan int),
+			done:    h.done,
+			first:   j == 0,
+			last:    j == n-1,
+			above:   fromAbove[j],
+			below:   fromBelow[j],
+			toAbove: fromBelow[(j-1+n)%n],
+			toBelow: fromAbove[(j+1)%n],
+		}
+		h.workers = append(h.workers, w)
+		go w.run(c)
+	}
+	return h
+}
+
+func (h *haloWorkers) nextTurn(turn int) error {
+	for _, w := range h.workers {
+		w.turns <- turn
+	}
+	for range h.workers {
+		<-h.done
+	}
+	return nil
+}
+
+// world copies the strips back into a single world.
+// It is only called between turns, when none of the workers are touching their strips.
+func (h *haloWorkers) world() ([][]uint8, error) {
+	var world [][]uint8
+	for _, w := range h.workers {
+		for _, row := range w.strip.rows() {
+			world = append(world, append([]uint8(nil), row...))
+		}
+	}
+	return world, nil
+}
+
+func (h *haloWorkers) close() {
+	for _, w := range h.workers {
+		close(w.turns)
+	}
+}
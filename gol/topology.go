@@ -0,0 +1,75 @@
+package gol
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Topology decides how the edges of the board are joined together.
+type Topology int
+
+const (
+	// Torus joins the left edge to the right edge and the top edge to the bottom edge.
+	Torus Topology = iota
+	// Bounded treats every cell outside the board as dead.
+	Bounded
+	// Cylinder joins the left edge to the right edge, while cells above and below the board are dead.
+	Cylinder
+	// KleinBottle joins the left edge to the right edge, and the top edge to the bottom edge mirrored left to right.
+	KleinBottle
+)
+
+var topologyNames = map[Topology]string{
+	Torus:       "torus",
+	Bounded:     "bounded",
+	Cylinder:    "cylinder",
+	KleinBottle: "klein",
+}
+
+// ParseTopology parses one of torus, bounded, cylinder or klein.
+func ParseTopology(name string) (Topology, error) {
+	for topology, topologyName := range topologyNames {
+		if strings.EqualFold(name, topologyName) {
+			return topology, nil
+		}
+	}
+	return Torus, errors.New("unknown topology " + strconv.Quote(name) + ", should be one of torus, bounded, cylinder or klein")
+}
+
+func (t Topology) String() string {
+	if name, ok := topologyNames[t]; ok {
+		return name
+	}
+	return "Incorrect Topology"
+}
+
+// Set allows a Topology to be used as a command line flag.
+func (t *Topology) Set(name string) error {
+	topology, err := ParseTopology(name)
+	if err != nil {
+		return err
+	}
+	*t = topology
+	return nil
+}
+
+// wrapsHorizontally reports whether the left and right edges of the board are joined.
+func (t Topology) wrapsHorizontally() bool {
+	return t != Bounded
+}
+
+// acrossEdge returns the halo row seen across the top or bottom edge of the board,
+// where row is the row on the other side of the board.
+func (t Topology) acrossEdge(row []uint8) []uint8 {
+	halo := make([]uint8, len(row))
+	switch t {
+	case Torus:
+		copy(halo, row)
+	case KleinBottle:
+		for x := range row {
+			halo[x] = row[len(row)-1-x]
+		}
+	}
+	return halo
+}
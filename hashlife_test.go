@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestHashLife tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using the HashLife backend.
+func TestHashLife(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Backend: gol.HashLife},
+		{ImageWidth: 64, ImageHeight: 64, Backend: gol.HashLife},
+		{ImageWidth: 512, ImageHeight: 512, Backend: gol.HashLife},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			testName := fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns)
+			t.Run(testName, func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestHashLifeLongRun tests the number of alive cells on the 512x512 image long after it has settled into a period of 2.
+func TestHashLifeLongRun(t *testing.T) {
+	alive := readAliveCounts(512, 512)
+	for _, turns := range []int{10000, 1000000001} {
+		p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: turns, Backend: gol.HashLife}
+		expected := alive[10000]
+		if turns%2 == 1 {
+			expected = 5567
+		} else if turns > 10000 {
+			expected = 5565
+		}
+		t.Run(fmt.Sprint(turns), func(t *testing.T) {
+			if actual := len(finalAlive(p)); actual != expected {
+				t.Errorf("After %v turns expected %v alive cells, got %v instead", turns, expected, actual)
+			}
+		})
+	}
+}
+
+// TestHashLifeTurns runs 100 turns of the 64x64 image with a report every 30 turns.
+// The leaps should stop at the same turns every time, including every turn a report is asked for.
+func TestHashLifeTurns(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, ReportEvery: 30, Backend: gol.HashLife}
+	expected := []int{16, 24, 28, 30, 46, 54, 58, 60, 76, 84, 88, 90, 98, 100}
+	for run := 0; run < 2; run++ {
+		events := make(chan gol.Event)
+		go gol.Run(p, events, nil)
+		var turns, reports []int
+		for event := range events {
+			switch event := event.(type) {
+			case gol.TurnComplete:
+				turns = append(turns, event.CompletedTurns)
+			case gol.StatsReport:
+				reports = append(reports, event.CompletedTurns)
+			}
+		}
+		if fmt.Sprint(turns) != fmt.Sprint(expected) {
+			t.Errorf("Expected turns %v to be completed, got %v", expected, turns)
+		}
+		if fmt.Sprint(reports) != fmt.Sprint([]int{30, 60, 90}) {
+			t.Errorf("Expected reports after turns 30, 60 and 90, got %v", reports)
+		}
+	}
+}
+
+// TestHashLifeTopology checks that a run with HashLife on a topology other than the torus is turned down with an error.
+func TestHashLifeTopology(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Backend: gol.HashLife, Topology: gol.Bounded}
+	events := make(chan gol.Event)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- gol.Run(p, events, nil)
+	}()
+	for range events {
+	}
+	if err := <-runErr; err == nil {
+		t.Error("Expected an error for HashLife on a bounded board")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestIOError checks that files which cannot be read or written are sent as IOError events and returned by gol.Run,
+// instead of crashing the program.
+func TestIOError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ioerror")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	notPgm := filepath.Join(dir, "16x16.pgm")
+	util.Check(ioutil.WriteFile(notPgm, []byte("GIF89a"), 0644))
+	wrongSize := filepath.Join(dir, "small.pgm")
+	util.Check(ioutil.WriteFile(wrongSize, []byte("P5\n2 2\n255\n\x00\x00\x00\x00"), 0644))
+
+	tests := []struct {
+		name     string
+		p        gol.Params
+		filename string
+		finished bool
+	}{
+		{"missing", gol.Params{ImageWidth: 16, ImageHeight: 16, Input: filepath.Join(dir, "missing.pgm")}, filepath.Join(dir, "missing.pgm"), false},
+		{"not-pgm", gol.Params{ImageWidth: 16, ImageHeight: 16, Input: dir}, notPgm, false},
+		{"wrong-size", gol.Params{ImageWidth: 16, ImageHeight: 16, Input: wrongSize}, wrongSize, false},
+		{"missing-pattern", gol.Params{Pattern: filepath.Join(dir, "missing.rle")}, filepath.Join(dir, "missing.rle"), false},
+		{"unwritable", gol.Params{ImageWidth: 16, ImageHeight: 16, Output: filepath.Join(notPgm, "out.pgm")}, filepath.Join(notPgm, "out.pgm"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.p.Turns = 10
+			test.p.Threads = 2
+			events := make(chan gol.Event)
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- gol.Run(test.p, events, nil)
+			}()
+			var ioErrors []gol.IOError
+			finished := false
+			for event := range events {
+				switch e := event.(type) {
+				case gol.IOError:
+					ioErrors = append(ioErrors, e)
+				case gol.FinalTurnComplete:
+					finished = true
+				}
+			}
+			if len(ioErrors) != 1 || ioErrors[0].Filename != test.filename {
+				t.Fatalf("Expected one IOError for %v, got %v", test.filename, ioErrors)
+			}
+			if err := <-runErr; err == nil {
+				t.Fatal("Expected gol.Run to return an error")
+			}
+			if finished != test.finished {
+				t.Fatalf("Expected the run to finish to be %v, got %v", test.finished, finished)
+			}
+		})
+	}
+}
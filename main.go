@@ -1,9 +1,15 @@
 package main
 
 import (
+	"encoding/csv"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
+	"strconv"
+	"syscall"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
@@ -24,13 +30,13 @@ func main() {
 		&params.ImageWidth,
 		"w",
 		512,
-		"Specify the width of the image. Defaults to 512.")
+		"Specify the width of the image. Defaults to 512, or the width of the -in image or -pattern.")
 
 	flag.IntVar(
 		&params.ImageHeight,
 		"h",
 		512,
-		"Specify the height of the image. Defaults to 512.")
+		"Specify the height of the image. Defaults to 512, or the height of the -in image or -pattern.")
 
 	flag.IntVar(
 		&params.Turns,
@@ -38,6 +44,172 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.Var(
+		&params.Rule,
+		"rule",
+		"Specify the rule in B/S notation, e.g. B36/S23 for HighLife. Defaults to the rule of the pattern, or B3/S23.")
+
+	flag.Var(
+		&params.Topology,
+		"topology",
+		"Specify how the edges of the board are joined: torus, bounded, cylinder or klein. Defaults to torus.")
+
+	flag.Var(
+		&params.Backend,
+		"backend",
+		"Specify how to process the turns on this machine: strips, bitboard or hashlife. Defaults to strips.")
+
+	flag.StringVar(
+		&params.Broker,
+		"broker",
+		"",
+		"Specify the address of a broker to process the turns on, e.g. 127.0.0.1:8030. Runs locally by default.")
+
+	flag.BoolVar(
+		&params.Attach,
+		"attach",
+		false,
+		"Attach to the run on -broker that a controller detached from with 'd', instead of loading an image. The size, rule and topology come from the broker, and so do the turns unless -turns is given.")
+
+	flag.StringVar(
+		&params.Resume,
+		"resume",
+		"",
+		"Specify a checkpoint to carry on from instead of loading an image. The size, rule and topology come from the checkpoint, and so do the turns unless -turns is given.")
+
+	flag.Float64Var(
+		&params.TurnsPerSecond,
+		"speed",
+		0,
+		"Specify the most turns to process every second, which can be changed while running with '+' and '-'. Defaults to 0, which is unlimited.")
+
+	flag.DurationVar(
+		&params.ReportInterval,
+		"report",
+		2*time.Second,
+		"Specify how often to report the number of alive cells and other statistics. Defaults to 2s.")
+
+	flag.IntVar(
+		&params.ReportEvery,
+		"reportEvery",
+		0,
+		"Specify how many turns to process between reporting statistics, as well as reporting them every -report. Defaults to 0, which only reports every -report.")
+
+	flag.IntVar(
+		&params.StepTurns,
+		"step",
+		10,
+		"Specify how many turns 'N' steps through while paused. Defaults to 10.")
+
+	flag.IntVar(
+		&params.History,
+		"history",
+		50,
+		"Specify how many earlier boards to keep while paused, so that 'b' can step back through the turns stepped with 'n' and 'N'. Defaults to 50, and 0 keeps none.")
+
+	flag.IntVar(
+		&params.CheckpointEvery,
+		"checkpoint",
+		0,
+		"Specify how many turns to process between saving checkpoints in out/. Defaults to 0, which only saves one when quitting.")
+
+	flag.StringVar(
+		&params.Pattern,
+		"pattern",
+		"",
+		"Specify an RLE, Life 1.06, plaintext or pgm pattern to place into an empty world instead of loading an image.")
+
+	flag.IntVar(
+		&params.PatternX,
+		"px",
+		0,
+		"Specify the column of the top left corner of the pattern. The pattern is centred unless -px or -py is given.")
+
+	flag.IntVar(
+		&params.PatternY,
+		"py",
+		0,
+		"Specify the row of the top left corner of the pattern. The pattern is centred unless -px or -py is given.")
+
+	flag.Var(
+		&params.OutputFormat,
+		"format",
+		"Specify the format to write the world out in: pgm, pbm, plainpgm, plainpbm, rle, life or cells. The plain formats are pgm and pbm written as text. Defaults to pgm.")
+
+	flag.StringVar(
+		&params.Input,
+		"in",
+		"",
+		"Specify the pgm or pbm image to load, or a directory holding it. {w} and {h} are replaced by the width and height. Defaults to images/{w}x{h}.pgm.")
+
+	flag.StringVar(
+		&params.Output,
+		"out",
+		"",
+		"Specify the file to write the world to, or a directory to write it in. {w}, {h}, {turn} and {turns} are replaced by the width, height, completed turns and total turns. Defaults to out/{w}x{h}x{turns}.")
+
+	flag.StringVar(
+		&params.Animation,
+		"animate",
+		"",
+		"Specify a file to write an animated GIF of the run to, or an APNG when it ends with .png. {w}, {h}, {turn} and {turns} are replaced like in -out. 'g' also starts and finishes an animation.")
+
+	flag.IntVar(
+		&params.AnimateFrom,
+		"animateFrom",
+		0,
+		"Specify the first turn in the animation. Defaults to 0.")
+
+	flag.IntVar(
+		&params.AnimateTo,
+		"animateTo",
+		0,
+		"Specify the last turn in the animation. Defaults to 0, which is the end of the run. The animation ends early once its frames hold 64 Mi cells, such as 256 frames of a 512x512 world.")
+
+	flag.IntVar(
+		&params.AnimateEvery,
+		"animateEvery",
+		1,
+		"Specify how many turns to process between frames of the animation. Defaults to 1.")
+
+	flag.IntVar(
+		&params.AnimateScale,
+		"animateScale",
+		1,
+		"Specify how many pixels wide and high each cell is drawn in the animation. Defaults to 1.")
+
+	params.AliveColour = gol.Colour{R: 255, G: 255, B: 255}
+	flag.Var(
+		&params.AliveColour,
+		"alive",
+		"Specify the colour of alive cells in the animation, as #rrggbb or a name such as white or green. Defaults to white.")
+
+	flag.Var(
+		&params.DeadColour,
+		"dead",
+		"Specify the colour of dead cells in the animation, as #rrggbb or a name such as black or blue. Defaults to black.")
+
+	flag.IntVar(
+		&params.AliveThreshold,
+		"threshold",
+		128,
+		"Specify the grey level from 1 to 255 at or above which a cell in a greyscale image is alive. Defaults to 128.")
+
+	statsFile := flag.String(
+		"stats",
+		"",
+		"Specify a CSV file to log every StatsReport to, alongside the window. Use -reportEvery 1 to log every turn.")
+
+	recordFile := flag.String(
+		"record",
+		"",
+		"Specify a file to record every event of the run to, so that it can be watched again with -replay.")
+
+	replayFile := flag.String(
+		"replay",
+		"",
+		"Specify a recording made with -record to play back instead of running. -speed and -step apply to the replay, and -history is how many turns can be stepped back through.")
+
 	noVis := flag.Bool(
 		"noVis",
 		false,
@@ -45,24 +217,184 @@ func main() {
 
 	flag.Parse()
 
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if (params.Resume != "" || params.Attach) && !given["turns"] {
+		params.Turns = 0
+	}
+	params.CentrePattern = !given["px"] && !given["py"]
+	params.RuleGiven = given["rule"]
+	if params.Input != "" || params.Pattern != "" {
+		if !given["w"] {
+			params.ImageWidth = 0
+		}
+		if !given["h"] {
+			params.ImageHeight = 0
+		}
+	}
+	if *noVis && *recordFile == "" {
+		// Nothing is shown, so the workers do not need to keep track of the cells that change.
+		params.Events = gol.StateEvents
+		if *statsFile != "" {
+			params.Events |= gol.ReportEvents
+		}
+	}
+	if params.Pattern == "" && !params.RuleGiven {
+		params.Rule = gol.Conway
+	}
+
+	// The size and rule may come from the input files, and the window needs to know the size before the run starts.
+	var recording *gol.Recording
+	var start *gol.Result
+	var err error
+	if *replayFile != "" {
+		var file *os.File
+		recording, file, err = openRecording(*replayFile)
+		if err == nil {
+			defer file.Close()
+			params.ImageWidth, params.ImageHeight = recording.Width, recording.Height
+		}
+	} else {
+		params, start, err = gol.LoadParams(params)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	fmt.Println("Rule:", params.Rule)
+	fmt.Println("Topology:", params.Topology)
 
-	keyPresses := make(chan rune, 10)
-	events := make(chan gol.Event, 1000)
+	commands := make(chan gol.Command, 10)
 
-	go gol.Run(params, events, keyPresses)
+	// The window skips frames rather than holding up the run when it cannot keep up, but every report is logged.
+	bus := gol.NewBus()
+	var window <-chan gol.Event
 	if !(*noVis) {
-		sdl.Run(params, events, keyPresses)
+		window = bus.Subscribe(1000, gol.Coalesce)
+	}
+	logErr := make(chan error, 1)
+	if *statsFile != "" {
+		reports := bus.Subscribe(1000, gol.Block)
+		go func() {
+			logErr <- logStats(*statsFile, reports)
+		}()
 	} else {
-		complete := false
-		for !complete {
-			event := <-events
-			switch event.(type) {
-			case gol.FinalTurnComplete:
-				complete = true
-			}
+		logErr <- nil
+	}
+	recordErr := make(chan error, 1)
+	if *recordFile != "" {
+		recorded := bus.Subscribe(1000, gol.Block)
+		go func() {
+			recordErr <- record(*recordFile, params, recorded)
+		}()
+	} else {
+		recordErr <- nil
+	}
+
+	// Quit with a checkpoint when the process is asked to stop, for example when a shared machine pre-empts the run.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		commands <- gol.Quit{}
+		<-signals
+		os.Exit(1)
+	}()
+
+	runErr := make(chan error, 1)
+	go func() {
+		if recording != nil {
+			runErr <- recording.Replay(params, bus.Events(), commands)
+			return
 		}
+		runErr <- gol.RunFrom(params, start, bus.Events(), commands)
+	}()
+	if window != nil {
+		sdl.Run(params, window, commands)
+	}
+
+	// Wait for the run to finish, so that any output has finished before exiting.
+	err = <-runErr
+	if statsErr := <-logErr; err == nil {
+		err = statsErr
+	}
+	if recordErr := <-recordErr; err == nil {
+		err = recordErr
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// logStats writes every StatsReport to a CSV file, one row per report.
+func logStats(filename string, events <-chan gol.Event) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		for range events {
+		}
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	_ = w.Write([]string{"turn", "alive", "births", "deaths", "turns_per_second", "min_x", "min_y", "max_x", "max_y"})
+	for event := range events {
+		if report, ok := event.(gol.StatsReport); ok {
+			_ = w.Write([]string{
+				strconv.Itoa(report.CompletedTurns),
+				strconv.Itoa(report.Alive),
+				strconv.Itoa(report.Births),
+				strconv.Itoa(report.Deaths),
+				strconv.FormatFloat(report.TurnsPerSecond, 'f', 1, 64),
+				strconv.Itoa(report.Min.X),
+				strconv.Itoa(report.Min.Y),
+				strconv.Itoa(report.Max.X),
+				strconv.Itoa(report.Max.Y),
+			})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// record writes every event to a recording file.
+func record(filename string, p gol.Params, events <-chan gol.Event) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		for range events {
+		}
+		return err
+	}
+	defer file.Close()
+
+	if err := gol.Record(file, p, events); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// openRecording opens a recording file made with -record.
+// The turns are read from the file as they are replayed, so it is left open until the replay has finished.
+func openRecording(filename string) (*gol.Recording, *os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recording, err := gol.ReadRecording(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
 	}
+	return recording, file, nil
 }
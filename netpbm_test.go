@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestNetpbm converts the 64x64 image into every netpbm variant, with comments and other maxvals,
+// and checks that each one gives the expected board after 100 turns.
+func TestNetpbm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netpbm")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	initialAlive := readAliveCells("images/64x64.pgm", p.ImageWidth, p.ImageHeight)
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+	alive := make(map[util.Cell]bool)
+	for _, cell := range initialAlive {
+		alive[cell] = true
+	}
+
+	// Dead cells are a dark grey below the threshold in the greyscale images.
+	images := map[string]func(x, y int) string{
+		"P1 64 64\n# a comment\n":           plainBit(alive),
+		"P2\n# from GIMP\n64 64\n1000\n":    plainGrey(alive, "400", "1000"),
+		"P2 64 64 15\n":                     plainGrey(alive, "3", "12"),
+		"P4\n64 64\n":                       nil,
+		"P5\n# ImageMagick\n64 64\n65535\n": nil,
+	}
+	for header, pixel := range images {
+		var image bytes.Buffer
+		image.WriteString(header)
+		for y := 0; y < p.ImageHeight; y++ {
+			var bits byte
+			for x := 0; x < p.ImageWidth; x++ {
+				switch {
+				case pixel != nil:
+					image.WriteString(pixel(x, y))
+				case header[:2] == "P4":
+					if alive[util.Cell{X: x, Y: y}] {
+						bits |= 0x80 >> uint(x%8)
+					}
+					if x%8 == 7 {
+						image.WriteByte(bits)
+						bits = 0
+					}
+				case alive[util.Cell{X: x, Y: y}]:
+					image.Write([]byte{0xff, 0xff})
+				default:
+					image.Write([]byte{0x20, 0x00})
+				}
+			}
+			image.WriteString("\n")
+			if header[:2] == "P4" || header[:2] == "P5" {
+				image.Truncate(image.Len() - 1)
+			}
+		}
+
+		filename := filepath.Join(dir, fmt.Sprintf("%v.pnm", header[:2]))
+		util.Check(ioutil.WriteFile(filename, image.Bytes(), 0644))
+		p.Input = filename
+		p.AliveThreshold = 150
+		t.Run(fmt.Sprintf("%q", header), func(t *testing.T) {
+			assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+		})
+	}
+}
+
+// TestNetpbmOutput writes the 64x64 image after 100 turns as a pbm bitmap and as plain text pgm and pbm images,
+// and reads each one back in. The plain images should start with P2 or P1 and have no line longer than 70 characters.
+func TestNetpbmOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netpbm")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	magics := map[gol.Format]string{gol.PBM: "P4", gol.PlainPGM: "P2", gol.PlainPBM: "P1"}
+	for format, magic := range magics {
+		t.Run(format.String(), func(t *testing.T) {
+			output := filepath.Join(dir, format.String())
+			p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, OutputFormat: format, Output: output}
+			finalAlive(p)
+
+			filename := output + format.Extension()
+			data, err := ioutil.ReadFile(filename)
+			util.Check(err)
+			if !bytes.HasPrefix(data, []byte(magic+"\n")) {
+				t.Errorf("Expected %v to start with %v", filename, magic)
+			}
+			if magic != "P4" {
+				for _, line := range bytes.Split(data, []byte("\n")) {
+					if len(line) > 70 {
+						t.Fatalf("Expected no line of %v to be longer than 70 characters, got %q", filename, line)
+					}
+				}
+			}
+
+			p = gol.Params{Threads: 1, Input: filename, Output: dir + "/"}
+			assertEqualBoard(t, finalAlive(p), expectedAlive, gol.Params{ImageWidth: 64, ImageHeight: 64})
+		})
+	}
+}
+
+// TestNetpbmEmpty checks that images with no width or height are turned down.
+func TestNetpbmEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netpbm")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	for i, header := range []string{"P1 0 64\n", "P5 64 0 255\n"} {
+		filename := filepath.Join(dir, fmt.Sprintf("%v.pgm", i))
+		util.Check(ioutil.WriteFile(filename, []byte(header), 0644))
+		events := make(chan gol.Event)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- gol.Run(gol.Params{Threads: 1, Input: filename, Turns: 1}, events, nil)
+		}()
+		for range events {
+		}
+		if err := <-errs; err == nil || !strings.Contains(err.Error(), "has no cells") {
+			t.Errorf("Expected %q to be turned down for having no cells, got %v", header, err)
+		}
+	}
+}
+
+// plainBit draws alive cells as black in a P1 bitmap, with whitespace only between some of the pixels.
+func plainBit(alive map[util.Cell]bool) func(x, y int) string {
+	return func(x, y int) string {
+		bit := "0"
+		if alive[util.Cell{X: x, Y: y}] {
+			bit = "1"
+		}
+		if x%3 == 0 {
+			bit += " "
+		}
+		return bit
+	}
+}
+
+// plainGrey draws a P2 image using the given grey levels for dead and alive cells.
+func plainGrey(alive map[util.Cell]bool, dead, live string) func(x, y int) string {
+	return func(x, y int) string {
+		if alive[util.Cell{X: x, Y: y}] {
+			return live + " "
+		}
+		return dead + " "
+	}
+}
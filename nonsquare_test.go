@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestNonSquare runs wide and tall random boards with both local backends and compares them with a simple Game of Life.
+func TestNonSquare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nonsquare")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	random := rand.New(rand.NewSource(1))
+	for _, size := range [][2]int{{1024, 16}, {16, 1024}, {100, 3}} {
+		width, height := size[0], size[1]
+		var image bytes.Buffer
+		fmt.Fprintf(&image, "P5\n%v %v\n255\n", width, height)
+		var initialAlive []util.Cell
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if random.Intn(3) == 0 {
+					image.WriteByte(255)
+					initialAlive = append(initialAlive, util.Cell{X: x, Y: y})
+				} else {
+					image.WriteByte(0)
+				}
+			}
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("%vx%v.pgm", width, height))
+		util.Check(ioutil.WriteFile(filename, image.Bytes(), 0644))
+
+		p := gol.Params{ImageWidth: width, ImageHeight: height, Turns: 20, Input: dir, Output: dir}
+		expectedAlive := referenceGol(initialAlive, p)
+		for _, backend := range []gol.Backend{gol.Strips, gol.Bitboard} {
+			p.Backend = backend
+			p.Threads = 3
+			t.Run(fmt.Sprintf("%vx%v-%v", width, height, backend), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+				written := filepath.Join(dir, fmt.Sprintf("%vx%vx%v.pgm", width, height, p.Turns))
+				assertEqualBoard(t, readAliveCells(written, width, height), expectedAlive, p)
+			})
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestPaths loads the 16x16 and 64x64 images from another directory without giving their size,
+// and checks that the output goes where the filename template says.
+func TestPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paths")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	for _, size := range []int{16, 64} {
+		data, err := ioutil.ReadFile(fmt.Sprintf("images/%vx%v.pgm", size, size))
+		util.Check(err)
+		input := filepath.Join(dir, fmt.Sprintf("dataset-%v.pgm", size))
+		util.Check(ioutil.WriteFile(input, data, 0644))
+
+		p := gol.Params{
+			Turns:   100,
+			Threads: 4,
+			Input:   input,
+			Output:  filepath.Join(dir, "results", "{w}x{h}", "turn-{turn}.pgm"),
+		}
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			finalAlive(p)
+			p.ImageWidth, p.ImageHeight = size, size
+			expectedAlive := readAliveCells(fmt.Sprintf("check/images/%vx%vx100.pgm", size, size), size, size)
+			output := filepath.Join(dir, "results", fmt.Sprintf("%vx%v", size, size), "turn-100.pgm")
+			assertEqualBoard(t, readAliveCells(output, size, size), expectedAlive, p)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// gliders is a glider written in every pattern format, with names that leave some formats to be detected from their contents.
+var gliders = map[string]string{
+	"glider.rle":   "#N Glider\n#C A comment\nx = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n",
+	"glider.lif":   "#Life 1.06\n1 0\n2 1\n0 2\n1 2\n2 2\n",
+	"glider.cells": "!Name: Glider\n.O\n..O\nOOO\n",
+	"glider":       "#Life 1.06\n1 0\n2 1\n0 2\n1 2\n2 2\n",
+	"glider.txt":   "!Name: Glider\n.O.\n..O\nOOO\n",
+}
+
+// TestPattern places a glider from every pattern format into a 16x16 world, both centred and at an offset.
+func TestPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patterns")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	for name, contents := range gliders {
+		filename := filepath.Join(dir, name)
+		util.Check(ioutil.WriteFile(filename, []byte(contents), 0644))
+		for _, centre := range []bool{true, false} {
+			p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 4, Threads: 2, Pattern: filename, PatternX: 1, PatternY: 2, CentrePattern: centre}
+			x, y := 1, 2
+			if centre {
+				x, y = 6, 6
+			}
+			// A glider moves one cell down and to the right every 4 turns.
+			expectedAlive := []util.Cell{{X: x + 2, Y: y + 1}, {X: x + 3, Y: y + 2}, {X: x + 1, Y: y + 3}, {X: x + 2, Y: y + 3}, {X: x + 3, Y: y + 3}}
+			t.Run(fmt.Sprintf("%v-centred-%v", name, centre), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestPatternRule checks that the rule in an RLE pattern is used unless another rule is given,
+// including when Golly has followed the rule with a bounded grid.
+func TestPatternRule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patterns")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "seeds.rle")
+	util.Check(ioutil.WriteFile(filename, []byte("x = 2, y = 1, rule = B2/S\n2o!\n"), 0644))
+
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Threads: 1, Pattern: filename, CentrePattern: true}
+	expectedAlive := []util.Cell{{X: 7, Y: 6}, {X: 8, Y: 6}, {X: 7, Y: 8}, {X: 8, Y: 8}}
+	t.Run("rle", func(t *testing.T) {
+		assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+	})
+	golly := filepath.Join(dir, "golly.rle")
+	util.Check(ioutil.WriteFile(golly, []byte("x = 2, y = 1, rule = B2/S:T20,20\n2o!\n"), 0644))
+	t.Run("golly", func(t *testing.T) {
+		p := p
+		p.Pattern = golly
+		assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+	})
+	p.Rule = gol.Conway
+	t.Run("given", func(t *testing.T) {
+		assertEqualBoard(t, finalAlive(p), nil, p)
+	})
+}
+
+// TestPatternInvalid checks that patterns with a size below 1 or no cells at all are turned down,
+// and that the error names the file once.
+func TestPatternInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "patterns")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	patterns := map[string]string{
+		"negative.rle": "x = -3, y = 3\nbo$2bo$3o!\n",
+		"empty.rle":    "x = 0, y = 0\n!\n",
+		"nosize.rle":   "rule = B3/S23\n!\n",
+		"empty.cells":  "!Name: Nothing\n",
+		"blank.cells":  "\n\n",
+	}
+	for name, contents := range patterns {
+		filename := filepath.Join(dir, name)
+		util.Check(ioutil.WriteFile(filename, []byte(contents), 0644))
+		t.Run(name, func(t *testing.T) {
+			_, _, err := gol.LoadParams(gol.Params{Turns: 1, Pattern: filename})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if n := strings.Count(err.Error(), filename); n != 1 {
+				t.Errorf("Expected the error to name the file once, got %q", err)
+			}
+		})
+	}
+}
+
+// TestPatternOutput writes the 64x64 image after 100 turns in every pattern format,
+// then reads each file back in as a pattern and checks that it is the same board.
+func TestPatternOutput(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+	for _, format := range []gol.Format{gol.RLE, gol.Life106, gol.Plaintext} {
+		t.Run(format.String(), func(t *testing.T) {
+			p.OutputFormat = format
+			finalAlive(p)
+			pattern := gol.Params{
+				ImageWidth:  p.ImageWidth,
+				ImageHeight: p.ImageHeight,
+				Threads:     1,
+				Pattern:     "out/64x64x100" + format.Extension(),
+			}
+			assertEqualBoard(t, finalAlive(pattern), expectedAlive, p)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRecord records 100 turns of the 64x64 image and replays them without a speed limit.
+// The replay should show the same board after every turn and send the same events apart from the flips.
+func TestRecord(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	bus := gol.NewBus()
+	recorded := bus.Subscribe(1000, gol.Block)
+	watched := bus.Subscribe(1000, gol.Block)
+
+	var recording bytes.Buffer
+	recordErr := make(chan error, 1)
+	go func() {
+		recordErr <- gol.Record(&recording, p, recorded)
+	}()
+	go gol.Run(p, bus.Events(), nil)
+	boards, others := watchBoards(watched)
+	util.Check(<-recordErr)
+
+	rec, err := gol.ReadRecording(&recording)
+	util.Check(err)
+	if rec.Width != 64 || rec.Height != 64 {
+		t.Fatalf("Expected a 64x64 recording, got %vx%v", rec.Width, rec.Height)
+	}
+	replayed := make(chan gol.Event)
+	go rec.Replay(gol.Params{}, replayed, nil)
+	replayedBoards, replayedOthers := watchBoards(replayed)
+
+	if len(replayedBoards) != len(boards) {
+		t.Fatalf("Expected %v turns to be replayed, got %v", len(boards), len(replayedBoards))
+	}
+	for turn, board := range boards {
+		if !sameCells(board, replayedBoards[turn]) {
+			t.Errorf("Expected turn %v to be replayed with the same board", turn)
+		}
+	}
+	if len(replayedOthers) != len(others) {
+		t.Fatalf("Expected %v other events to be replayed, got %v", len(others), len(replayedOthers))
+	}
+	for i := range others {
+		if !reflect.DeepEqual(others[i], replayedOthers[i]) {
+			t.Errorf("Expected %v to be replayed, got %v", others[i], replayedOthers[i])
+		}
+	}
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	if len(boards[100]) != len(expectedAlive) {
+		t.Errorf("Expected %v alive cells after 100 turns, got %v", len(expectedAlive), len(boards[100]))
+	}
+}
+
+// TestReplayStepBack pauses a replay, steps forward 5 turns and back 2, then quits.
+// Every turn should show the same board as when it was recorded.
+func TestReplayStepBack(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 50, Threads: 4}
+	bus := gol.NewBus()
+	recorded := bus.Subscribe(1000, gol.Block)
+	watched := bus.Subscribe(1000, gol.Block)
+
+	var recording bytes.Buffer
+	recordErr := make(chan error, 1)
+	go func() {
+		recordErr <- gol.Record(&recording, p, recorded)
+	}()
+	go gol.Run(p, bus.Events(), nil)
+	boards, _ := watchBoards(watched)
+	util.Check(<-recordErr)
+
+	rec, err := gol.ReadRecording(&recording)
+	util.Check(err)
+	replayed := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	reply := make(chan gol.Ack, 1)
+	go rec.Replay(gol.Params{StepTurns: 5, History: 10}, replayed, commands)
+
+	script := []gol.Command{
+		gol.Pause{Reply: reply},
+		gol.Step{Reply: reply},
+		gol.StepBack{Reply: reply},
+		gol.StepBack{Reply: reply},
+		gol.Quit{Reply: reply},
+	}
+	commands <- script[0]
+	alive := make(map[util.Cell]bool)
+	var acks []gol.Ack
+	for replayed != nil {
+		select {
+		case event, ok := <-replayed:
+			if !ok {
+				replayed = nil
+				break
+			}
+			switch event := event.(type) {
+			case gol.CellsFlipped:
+				for _, cell := range event.Cells {
+					alive[cell] = !alive[cell]
+				}
+			case gol.TurnComplete:
+				if !sameCells(aliveCells(alive), boards[event.CompletedTurns]) {
+					t.Errorf("Expected turn %v to show the recorded board", event.CompletedTurns)
+				}
+			}
+		case ack := <-reply:
+			acks = append(acks, ack)
+			if len(acks) < len(script) {
+				commands <- script[len(acks)]
+			}
+		}
+	}
+	if len(acks) < len(script) {
+		acks = append(acks, <-reply)
+	}
+
+	paused := acks[0].CompletedTurns
+	expected := []int{paused, paused + 5, paused + 4, paused + 3, paused + 3}
+	for i, turn := range expected {
+		if acks[i].CompletedTurns != turn {
+			t.Errorf("Expected %T to be acknowledged at turn %v, got %v", script[i], turn, acks[i].CompletedTurns)
+		}
+		if acks[i].Err != nil {
+			t.Errorf("Expected %T to succeed, got %v", script[i], acks[i].Err)
+		}
+	}
+}
+
+// TestReplayStepBackToStart pauses a replay, steps it on 10 turns and then steps back through every turn replayed so far,
+// as far as the initial board.
+// The board shown at turn 0 should be the 16x16 image, and there should be nothing before it.
+func TestReplayStepBackToStart(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+	events := make(chan gol.Event)
+	var recording bytes.Buffer
+	recordErr := make(chan error, 1)
+	go func() {
+		recordErr <- gol.Record(&recording, p, events)
+	}()
+	go gol.Run(p, events, nil)
+	util.Check(<-recordErr)
+
+	rec, err := gol.ReadRecording(&recording)
+	util.Check(err)
+	replayed := make(chan gol.Event)
+	commands := make(chan gol.Command)
+	reply := make(chan gol.Ack)
+	replayErr := make(chan error, 1)
+	go func() {
+		replayErr <- rec.Replay(gol.Params{TurnsPerSecond: 1000, History: 100}, replayed, commands)
+	}()
+	watched := make(chan map[int]map[util.Cell]bool)
+	go func() {
+		boards, _ := watchBoards(replayed)
+		watched <- boards
+	}()
+
+	commands <- gol.Pause{Reply: reply}
+	<-reply
+	commands <- gol.Step{Turns: 10, Reply: reply}
+	stepped := (<-reply).CompletedTurns
+	for turn := stepped - 1; turn >= 0; turn-- {
+		commands <- gol.StepBack{Reply: reply}
+		if ack := <-reply; ack.Err != nil || ack.CompletedTurns != turn {
+			t.Fatalf("Expected to step back to turn %v, got turn %v and %v", turn, ack.CompletedTurns, ack.Err)
+		}
+	}
+	commands <- gol.StepBack{Reply: reply}
+	if ack := <-reply; ack.Err == nil {
+		t.Error("Expected an error when stepping back past the initial board")
+	}
+	commands <- gol.Quit{Reply: reply}
+	<-reply
+	boards := <-watched
+	util.Check(<-replayErr)
+
+	initial := make(map[util.Cell]bool)
+	for _, cell := range readAliveCells("images/16x16.pgm", 16, 16) {
+		initial[cell] = true
+	}
+	if !sameCells(boards[0], initial) {
+		t.Errorf("Expected turn 0 to show the initial board")
+	}
+}
+
+// watchBoards keeps track of the board from the flips until events is closed.
+// It returns the board shown at every TurnComplete and every event other than the flips and TurnComplete.
+func watchBoards(events <-chan gol.Event) (map[int]map[util.Cell]bool, []gol.Event) {
+	alive := make(map[util.Cell]bool)
+	boards := make(map[int]map[util.Cell]bool)
+	var others []gol.Event
+	for event := range events {
+		switch event := event.(type) {
+		case gol.CellsFlipped:
+			for _, cell := range event.Cells {
+				alive[cell] = !alive[cell]
+			}
+		case gol.TurnComplete:
+			boards[event.CompletedTurns] = aliveCells(alive)
+		case gol.FinalTurnComplete:
+			others = append(others, gol.FinalTurnComplete{CompletedTurns: event.CompletedTurns, Alive: sortedCells(event.Alive)})
+		default:
+			others = append(others, event)
+		}
+	}
+	return boards, others
+}
+
+// aliveCells returns the cells that are alive after they have been flipped.
+func aliveCells(flipped map[util.Cell]bool) map[util.Cell]bool {
+	alive := make(map[util.Cell]bool)
+	for cell, isAlive := range flipped {
+		if isAlive {
+			alive[cell] = true
+		}
+	}
+	return alive
+}
+
+func sortedCells(cells []util.Cell) []util.Cell {
+	sorted := append([]util.Cell(nil), cells...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Y < sorted[j].Y || sorted[i].Y == sorted[j].Y && sorted[i].X < sorted[j].X
+	})
+	return sorted
+}
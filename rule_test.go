@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestParseRule checks that rulestrings in B/S and S/B notation are parsed and printed in B/S notation.
+func TestParseRule(t *testing.T) {
+	tests := map[string]string{
+		"B3/S23":       "B3/S23",
+		"b36/s23":      "B36/S23",
+		"S23/B3":       "B3/S23",
+		"23/3":         "B3/S23",
+		"B2/S":         "B2/S",
+		"B3678/S34678": "B3678/S34678",
+	}
+	for rulestring, expected := range tests {
+		rule, err := gol.ParseRule(rulestring)
+		if err != nil {
+			t.Errorf("Parsing %v failed: %v", rulestring, err)
+		} else if rule.String() != expected {
+			t.Errorf("Parsing %v gave %v, expected %v", rulestring, rule, expected)
+		}
+	}
+	for _, rulestring := range []string{"", "B3", "B39/S23", "X3/S23", "B3/S2/3"} {
+		if _, err := gol.ParseRule(rulestring); err == nil {
+			t.Errorf("Parsing %q should have failed", rulestring)
+		}
+	}
+}
+
+// TestRule tests that giving Conway's rule explicitly matches the 16x16 and 64x64 images after 100 turns.
+func TestRule(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4},
+	}
+	for _, p := range tests {
+		rule, err := gol.ParseRule("B3/S23")
+		util.Check(err)
+		p.Rule = rule
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+		t.Run(testName, func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var cells []util.Cell
+			for event := range events {
+				switch e := event.(type) {
+				case gol.FinalTurnComplete:
+					cells = e.Alive
+				}
+			}
+			assertEqualBoard(t, cells, expectedAlive, p)
+		})
+	}
+}
+
+// TestOtherRules tests rules other than Conway's on the strips engine against boards worked out by hand.
+// Two rows of three cells on a bounded 5x5 board become a column of five under HighLife, where Conway's rule leaves a gap in the middle.
+// Two cells next to each other become two pairs under Seeds, and every cell dies under B/S.
+func TestOtherRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		rule          string
+		width, height int
+		pattern       string
+		expected      []util.Cell
+	}{
+		{"B36/S23", 5, 5, ".....\n.OOO.\n.....\n.OOO.\n.....\n", []util.Cell{{X: 2, Y: 0}, {X: 2, Y: 1}, {X: 2, Y: 2}, {X: 2, Y: 3}, {X: 2, Y: 4}}},
+		{"B3/S23", 5, 5, ".....\n.OOO.\n.....\n.OOO.\n.....\n", []util.Cell{{X: 2, Y: 0}, {X: 2, Y: 1}, {X: 2, Y: 3}, {X: 2, Y: 4}}},
+		{"B2/S", 4, 3, "....\n.OO.\n....\n", []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 2}, {X: 2, Y: 2}}},
+		{"B/S", 4, 3, "....\n.OO.\n....\n", nil},
+	}
+	for i, test := range tests {
+		rule, err := gol.ParseRule(test.rule)
+		util.Check(err)
+		pattern := filepath.Join(dir, strconv.Itoa(i)+".cells")
+		util.Check(ioutil.WriteFile(pattern, []byte(test.pattern), 0644))
+		for _, threads := range []int{1, 2} {
+			p := gol.Params{
+				ImageWidth:  test.width,
+				ImageHeight: test.height,
+				Turns:       1,
+				Threads:     threads,
+				Rule:        rule,
+				RuleGiven:   true,
+				Topology:    gol.Bounded,
+				Pattern:     pattern,
+				Output:      dir + "/",
+			}
+			t.Run(fmt.Sprintf("%v-%d", test.rule, threads), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), test.expected, p)
+			})
+		}
+	}
+}
+
+// TestEmptyRule tests that B/S is not mistaken for a rule that was not given when RuleGiven is set,
+// so every cell of the 16x16 image dies, and that it is otherwise replaced by Conway's Game of Life.
+func TestEmptyRule(t *testing.T) {
+	rule, err := gol.ParseRule("B/S")
+	util.Check(err)
+	if rule.String() != "B/S" {
+		t.Errorf("Expected B/S to be printed as B/S, got %v", rule)
+	}
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Threads: 4, Rule: rule, RuleGiven: true}
+	if alive := finalAlive(p); len(alive) != 0 {
+		t.Errorf("Expected no cells to be alive under B/S, got %v", len(alive))
+	}
+
+	p.RuleGiven = false
+	loaded, _, err := gol.LoadParams(p)
+	util.Check(err)
+	if loaded.Rule != gol.Conway {
+		t.Errorf("Expected the rule to be B3/S23 when none was given, got %v", loaded.Rule)
+	}
+}
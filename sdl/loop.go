@@ -6,7 +6,8 @@ import (
 	"uk.ac.bris.cs/gameoflife/gol"
 )
 
-func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+// Run shows the world in a window, sending a command to the run for each key that is pressed.
+func Run(p gol.Params, events <-chan gol.Event, commands chan<- gol.Command) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
 
 sdlLoop:
@@ -17,13 +18,29 @@ sdlLoop:
 			case *sdl.KeyboardEvent:
 				switch e.Keysym.Sym {
 				case sdl.K_p:
-					keyPresses <- 'p'
+					commands <- gol.TogglePause{}
 				case sdl.K_s:
-					keyPresses <- 's'
+					commands <- gol.Save{}
 				case sdl.K_q:
-					keyPresses <- 'q'
+					commands <- gol.Quit{}
 				case sdl.K_k:
-					keyPresses <- 'k'
+					commands <- gol.Shutdown{}
+				case sdl.K_d:
+					commands <- gol.Detach{}
+				case sdl.K_n:
+					if e.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+						commands <- gol.Step{}
+					} else {
+						commands <- gol.Step{Turns: 1}
+					}
+				case sdl.K_b:
+					commands <- gol.StepBack{}
+				case sdl.K_PLUS, sdl.K_EQUALS, sdl.K_KP_PLUS:
+					commands <- gol.ChangeSpeed{Steps: 1}
+				case sdl.K_MINUS, sdl.K_KP_MINUS:
+					commands <- gol.ChangeSpeed{Steps: -1}
+				case sdl.K_g:
+					commands <- gol.Animate{}
 				}
 			}
 		}
@@ -34,6 +51,10 @@ sdlLoop:
 				break sdlLoop
 			}
 			switch e := event.(type) {
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					w.FlipPixel(cell.X, cell.Y)
+				}
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
 			case gol.TurnComplete:
@@ -54,10 +54,12 @@ sdlLoop:
 				break sdlLoop
 			}
 			switch e := event.(type) {
-			case gol.CellFlipped:
-				board[e.Cell.Y][e.Cell.X] = ^board[e.Cell.Y][e.Cell.X]
-				if w != nil {
-					w.FlipPixel(e.Cell.X, e.Cell.Y)
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					board[cell.Y][cell.X] = ^board[cell.Y][cell.X]
+					if w != nil {
+						w.FlipPixel(cell.X, cell.Y)
+					}
 				}
 			case gol.TurnComplete:
 				if w != nil {
@@ -102,7 +104,7 @@ func TestSdl(t *testing.T) {
 		final := false
 		for event := range events {
 			switch e := event.(type) {
-			case gol.CellFlipped:
+			case gol.CellsFlipped:
 				sdlEvents <- e
 			case gol.TurnComplete:
 				turnNum++
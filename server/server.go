@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// main starts a worker server with 'go run ./server -port 8040'
+func main() {
+	port := flag.String(
+		"port",
+		"8040",
+		"Specify the port to listen on for the broker. Defaults to 8040.")
+
+	flag.Parse()
+
+	worker := &gol.WorkerServer{}
+	err := rpc.Register(worker)
+	util.Check(err)
+	listener, err := net.Listen("tcp", ":"+*port)
+	util.Check(err)
+
+	fmt.Println("Worker listening on port", *port)
+	go rpc.Accept(listener)
+
+	// The worker exits once the broker shuts it down.
+	<-worker.Done()
+	fmt.Println("Worker shut down")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestShutdown shuts down a run on a broker with two worker servers,
+// checking that the board is saved and that the broker and both workers are told to exit.
+func TestShutdown(t *testing.T) {
+	workers := []*gol.WorkerServer{{}, {}}
+	var addresses []string
+	for _, worker := range workers {
+		listener := serveRpc(worker)
+		defer listener.Close()
+		addresses = append(addresses, listener.Addr().String())
+	}
+	broker, err := gol.NewBroker(addresses)
+	util.Check(err)
+	defer broker.Close()
+	brokerListener := serveRpc(broker)
+	defer brokerListener.Close()
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Broker: brokerListener.Addr().String()}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	go gol.RunWithCommands(p, events, commands)
+
+	reply := make(chan gol.Ack, 1)
+	saved := false
+	for event := range events {
+		switch event.(type) {
+		case gol.TurnComplete:
+			if event.GetCompletedTurns() == 10 {
+				commands <- gol.Shutdown{Reply: reply}
+			}
+		case gol.ImageOutputComplete:
+			saved = true
+		}
+	}
+	if ack := <-reply; ack.Err != nil {
+		t.Fatal(ack.Err)
+	}
+	if !saved {
+		t.Fatal("Expected the board to be saved before shutting down")
+	}
+
+	for _, done := range []<-chan struct{}{broker.Done(), workers[0].Done(), workers[1].Done()} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the broker and every worker to be shut down")
+		}
+	}
+}
+
+// TestShutdownExit shuts down a run on a broker that drops every connection as it shuts down, like the broker process exiting.
+// The controller should finish the run with the board it saved, without asking the broker for it again.
+func TestShutdownExit(t *testing.T) {
+	worker := serveRpc(&gol.WorkerServer{})
+	defer worker.Close()
+	broker, err := gol.NewBroker([]string{worker.Addr().String()})
+	util.Check(err)
+	defer broker.Close()
+
+	server := rpc.NewServer()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	util.Check(err)
+	listener := &flakyListener{Listener: inner, stalled: make(chan struct{}), killed: make(chan struct{})}
+	defer listener.kill()
+	util.Check(server.RegisterName("Broker", &exitingBroker{Broker: broker, listener: listener}))
+	go server.Accept(listener)
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Broker: listener.Addr().String()}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- gol.RunWithCommands(p, events, commands)
+	}()
+
+	reply := make(chan gol.Ack, 1)
+	var final *gol.FinalTurnComplete
+	for event := range events {
+		switch event := event.(type) {
+		case gol.TurnComplete:
+			if event.CompletedTurns == 10 {
+				commands <- gol.Shutdown{Reply: reply}
+			}
+		case gol.FinalTurnComplete:
+			final = &event
+		}
+	}
+	if ack := <-reply; ack.Err != nil {
+		t.Fatal(ack.Err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatal(err)
+	}
+	if final == nil {
+		t.Fatal("Expected a FinalTurnComplete event with the board saved when shutting down")
+	}
+}
+
+// exitingBroker is a broker that closes every connection to it once it has been shut down.
+type exitingBroker struct {
+	*gol.Broker
+	listener *flakyListener
+}
+
+func (b *exitingBroker) Shutdown(req gol.ShutdownRequest, res *gol.ShutdownResponse) error {
+	err := b.Broker.Shutdown(req, res)
+	b.listener.kill()
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSimulation runs the 64x64 image for 100 turns as a Simulation and checks the result.
+func TestSimulation(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", p.ImageWidth, p.ImageHeight)
+
+	simulation := gol.NewSimulation(p, nil)
+	if err := simulation.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Start(context.Background()); err == nil {
+		t.Fatal("Expected starting the simulation twice to fail")
+	}
+	result, err := simulation.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CompletedTurns != p.Turns {
+		t.Fatalf("Expected %v completed turns, got %v", p.Turns, result.CompletedTurns)
+	}
+	assertEqualBoard(t, result.Board.Alive(), expectedAlive, p)
+
+	board, turn := simulation.Snapshot()
+	if turn != p.Turns {
+		t.Fatalf("Expected a snapshot after the simulation has stopped to be at turn %v, got %v", p.Turns, turn)
+	}
+	assertEqualBoard(t, board.Alive(), expectedAlive, p)
+}
+
+// TestSimulationControl pauses a long simulation, checks that its snapshots stay the same while it is paused,
+// then resumes it and cancels its context.
+func TestSimulationControl(t *testing.T) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100000000, Threads: 8}
+	ctx, cancel := context.WithCancel(context.Background())
+	simulation := gol.NewSimulation(p, nil)
+	if err := simulation.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	simulation.Pause()
+	board, turn := simulation.Snapshot()
+	time.Sleep(200 * time.Millisecond)
+	pausedBoard, pausedTurn := simulation.Snapshot()
+	if turn != pausedTurn {
+		t.Fatalf("Expected no turns while paused, went from turn %v to %v", turn, pausedTurn)
+	}
+	assertEqualBoard(t, pausedBoard.Alive(), board.Alive(), p)
+
+	simulation.Resume()
+	time.Sleep(200 * time.Millisecond)
+	if _, resumedTurn := simulation.Snapshot(); resumedTurn <= turn {
+		t.Fatalf("Expected more turns after resuming at turn %v, got %v", turn, resumedTurn)
+	}
+
+	cancel()
+	stopped := make(chan struct{})
+	var result gol.Result
+	var err error
+	go func() {
+		result, err = simulation.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The simulation did not stop after its context was cancelled")
+	}
+	if err != context.Canceled {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+	if result.CompletedTurns <= turn || result.CompletedTurns >= p.Turns {
+		t.Fatalf("Expected the simulation to stop part of the way through, stopped at turn %v", result.CompletedTurns)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestSpeed starts a run limited to 20 turns per second, slows it down a step to 10 turns per second,
+// then checks how many turns are processed each second and the speed in the AliveCellsCount event.
+func TestSpeed(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, TurnsPerSecond: 20}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command)
+	go gol.RunWithCommands(p, events, commands)
+	status := make(chan gol.AliveCellsCount, 1)
+	go func() {
+		for event := range events {
+			if count, ok := event.(gol.AliveCellsCount); ok && len(status) == 0 {
+				status <- count
+			}
+		}
+	}()
+
+	reply := make(chan gol.Ack, 1)
+	apply := func(command gol.Command) int {
+		commands <- command
+		return (<-reply).CompletedTurns
+	}
+	measure := func(steps int) int {
+		start := apply(gol.ChangeSpeed{Steps: steps, Reply: reply})
+		time.Sleep(time.Second)
+		return apply(gol.ChangeSpeed{Reply: reply}) - start
+	}
+
+	if turns := measure(0); turns < 14 || turns > 26 {
+		t.Errorf("Expected about 20 turns in a second at 20 turns per second, got %v", turns)
+	}
+	if turns := measure(-1); turns < 7 || turns > 13 {
+		t.Errorf("Expected about 10 turns in a second after slowing down to 10 turns per second, got %v", turns)
+	}
+	select {
+	case count := <-status:
+		if count.TurnsPerSecond != 10 {
+			t.Errorf("Expected AliveCellsCount to report 10 turns per second, got %v", count.TurnsPerSecond)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No AliveCellsCount event received")
+	}
+	if turns := measure(10); turns < 1000 {
+		t.Errorf("Expected more than 1000 turns in a second with no limit, got %v", turns)
+	}
+	apply(gol.Quit{Reply: reply})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestStatsReport reports statistics after every turn of the 16x16 image,
+// checking them against the reference Game of Life, and then reports them every 100ms of a run at 100 turns per second.
+// Only a low bound is put on the turns per second, as a slow machine may not keep up with the speed asked for.
+func TestStatsReport(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 20, ReportEvery: 1}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+
+	alive := readAliveCells("check/images/16x16x0.pgm", 16, 16)
+	turn := 0
+	for event := range events {
+		report, ok := event.(gol.StatsReport)
+		if !ok || report.CompletedTurns == 0 {
+			continue
+		}
+		if report.CompletedTurns != turn+1 {
+			t.Fatalf("Expected a report after turn %v, got one after turn %v", turn+1, report.CompletedTurns)
+		}
+		turn++
+		next := referenceGol(alive, gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1})
+		births, deaths := changedCells(alive, next)
+		alive = next
+
+		min, max := util.Cell{X: 16, Y: 16}, util.Cell{X: -1, Y: -1}
+		for _, cell := range alive {
+			min.X, min.Y = minInt(min.X, cell.X), minInt(min.Y, cell.Y)
+			max.X, max.Y = maxInt(max.X, cell.X), maxInt(max.Y, cell.Y)
+		}
+		if report.Alive != len(alive) || report.Births != births || report.Deaths != deaths || report.Min != min || report.Max != max {
+			t.Fatalf("Expected %v alive, %v births, %v deaths inside %v to %v after turn %v, got %v",
+				len(alive), births, deaths, min, max, turn, report)
+		}
+	}
+	if turn != p.Turns {
+		t.Fatalf("Expected %v reports, got %v", p.Turns, turn)
+	}
+
+	p = gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, TurnsPerSecond: 100, ReportInterval: 100 * time.Millisecond}
+	events = make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	go gol.RunWithCommands(p, events, commands)
+	time.AfterFunc(550*time.Millisecond, func() {
+		commands <- gol.Quit{}
+	})
+	var reports []gol.StatsReport
+	counts := 0
+	for event := range events {
+		switch event := event.(type) {
+		case gol.StatsReport:
+			reports = append(reports, event)
+		case gol.AliveCellsCount:
+			counts++
+		}
+	}
+	if len(reports) < 3 || len(reports) > 7 || counts != len(reports) {
+		t.Fatalf("Expected about 5 StatsReport and AliveCellsCount events in half a second, got %v and %v", len(reports), counts)
+	}
+	for _, report := range reports[1:] {
+		if report.TurnsPerSecond < 10 {
+			t.Errorf("Expected at least 10 turns per second, got %v", report.TurnsPerSecond)
+		}
+	}
+}
+
+// TestStatsReportBlinker reports every 2 turns of a blinker, which is back where it was at every report,
+// but 2 cells are born and 2 die in every turn, so each report should add up 4 births and 4 deaths.
+func TestStatsReportBlinker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	util.Check(err)
+	defer os.RemoveAll(dir)
+
+	pattern := filepath.Join(dir, "blinker.cells")
+	util.Check(ioutil.WriteFile(pattern, []byte(".....\n.....\n.OOO.\n.....\n.....\n"), 0644))
+	p := gol.Params{Turns: 6, Threads: 1, Pattern: pattern, ReportEvery: 2, Output: dir + "/"}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	reports := 0
+	for event := range events {
+		if report, ok := event.(gol.StatsReport); ok && report.CompletedTurns > 0 {
+			reports++
+			if report.Alive != 3 || report.Births != 4 || report.Deaths != 4 {
+				t.Errorf("Expected 3 alive, 4 births and 4 deaths after turn %v, got %v", report.CompletedTurns, report)
+			}
+		}
+	}
+	if reports != 3 {
+		t.Errorf("Expected 3 reports, got %v", reports)
+	}
+}
+
+// changedCells counts the cells in after that are not in before, and the other way round.
+func changedCells(before, after []util.Cell) (born, died int) {
+	was := make(map[util.Cell]bool)
+	for _, cell := range before {
+		was[cell] = true
+	}
+	for _, cell := range after {
+		if was[cell] {
+			delete(was, cell)
+		} else {
+			born++
+		}
+	}
+	return born, len(was)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
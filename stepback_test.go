@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestStepBack pauses a run on each backend, steps forward 3 turns and then 1 turn, then steps back until the history of 3 boards runs out.
+// The board built from the CellsFlipped events should be the same every time a turn is shown again.
+func TestStepBack(t *testing.T) {
+	for _, backend := range []gol.Backend{gol.Strips, gol.Bitboard, gol.HashLife} {
+		t.Run(backend.String(), func(t *testing.T) {
+			p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, StepTurns: 3, History: 3, Backend: backend}
+			events := make(chan gol.Event)
+			commands := make(chan gol.Command, 1)
+			reply := make(chan gol.Ack, 1)
+			go gol.RunWithCommands(p, events, commands)
+
+			back := gol.StepBack{Reply: reply}
+			script := []gol.Command{
+				gol.Pause{Reply: reply},
+				gol.Step{Reply: reply},
+				gol.Step{Turns: 1, Reply: reply},
+				back, back, back, back,
+				gol.Quit{Reply: reply},
+			}
+			commands <- script[0]
+
+			alive := make(map[util.Cell]bool)
+			shown := make(map[int]map[util.Cell]bool)
+			var acks []gol.Ack
+			for events != nil {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						events = nil
+						break
+					}
+					switch event := event.(type) {
+					case gol.CellsFlipped:
+						for _, cell := range event.Cells {
+							alive[cell] = !alive[cell]
+						}
+					case gol.TurnComplete:
+						board := make(map[util.Cell]bool)
+						for cell, isAlive := range alive {
+							if isAlive {
+								board[cell] = true
+							}
+						}
+						if before, ok := shown[event.CompletedTurns]; ok && !sameCells(before, board) {
+							t.Errorf("Expected turn %v to show the same board after stepping back", event.CompletedTurns)
+						}
+						shown[event.CompletedTurns] = board
+					}
+				case ack := <-reply:
+					acks = append(acks, ack)
+					if len(acks) < len(script) {
+						commands <- script[len(acks)]
+					}
+				}
+			}
+
+			paused := acks[0].CompletedTurns
+			expected := []int{paused, paused + 3, paused + 4, paused + 3, paused + 2, paused + 1, paused + 1}
+			for i, turn := range expected {
+				if acks[i].CompletedTurns != turn {
+					t.Errorf("Expected %T to be acknowledged at turn %v, got %v", script[i], turn, acks[i].CompletedTurns)
+				}
+			}
+			for i := 3; i < 6; i++ {
+				if acks[i].Err != nil {
+					t.Errorf("Expected to step back, got %v", acks[i].Err)
+				}
+			}
+			if acks[6].Err == nil {
+				t.Error("Expected an error when stepping back past the history")
+			}
+		})
+	}
+}
+
+// TestStepBackAfterResume steps a paused run forward, lets it carry on and pauses it again.
+// The board kept while it was first paused should have been forgotten, so there is nothing to step back to.
+func TestStepBackAfterResume(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, History: 3}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command)
+	reply := make(chan gol.Ack)
+	go gol.RunWithCommands(p, events, commands)
+	go func() {
+		for range events {
+		}
+	}()
+
+	script := []gol.Command{
+		gol.Pause{Reply: reply},
+		gol.Step{Turns: 1, Reply: reply},
+		gol.Resume{Reply: reply},
+		gol.Pause{Reply: reply},
+		gol.StepBack{Reply: reply},
+		gol.Quit{Reply: reply},
+	}
+	var acks []gol.Ack
+	for _, command := range script {
+		commands <- command
+		acks = append(acks, <-reply)
+	}
+	if acks[4].Err == nil {
+		t.Errorf("Expected an error when stepping back after carrying on, got a step back to turn %v", acks[4].CompletedTurns)
+	}
+}
+
+func sameCells(a, b map[util.Cell]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for cell := range a {
+		if !b[cell] {
+			return false
+		}
+	}
+	return true
+}
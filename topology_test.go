@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestTopology tests the 16x16 and 64x64 images on 100 turns with every topology and backend using 1, 3 and 8 worker threads.
+// The expected boards come from a simple single threaded Game of Life.
+func TestTopology(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Turns: 100},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 100},
+	}
+	for _, p := range tests {
+		for _, topology := range []gol.Topology{gol.Torus, gol.Bounded, gol.Cylinder, gol.KleinBottle} {
+			p.Topology = topology
+			initialAlive := readAliveCells(
+				"images/"+fmt.Sprintf("%vx%v.pgm", p.ImageWidth, p.ImageHeight),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			expectedAlive := referenceGol(initialAlive, p)
+			for _, backend := range []gol.Backend{gol.Strips, gol.Bitboard} {
+				p.Backend = backend
+				for _, threads := range []int{1, 3, 8} {
+					p.Threads = threads
+					testName := fmt.Sprintf("%dx%dx%d-%d-%v-%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, p.Topology, p.Backend)
+					t.Run(testName, func(t *testing.T) {
+						assertEqualBoard(t, finalAlive(p), expectedAlive, p)
+					})
+				}
+			}
+		}
+	}
+}
+
+// referenceGol evolves the alive cells for p.Turns turns of Conway's Game of Life on the topology in p.
+func referenceGol(alive []util.Cell, p gol.Params) []util.Cell {
+	world := make([][]bool, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]bool, p.ImageWidth)
+	}
+	for _, cell := range alive {
+		world[cell.Y][cell.X] = true
+	}
+
+	for turn := 0; turn < p.Turns; turn++ {
+		newWorld := make([][]bool, p.ImageHeight)
+		for y := range newWorld {
+			newWorld[y] = make([]bool, p.ImageWidth)
+			for x := range newWorld[y] {
+				n := 0
+				for i := -1; i <= 1; i++ {
+					for j := -1; j <= 1; j++ {
+						nx, ny, ok := referenceNeighbour(x+j, y+i, p)
+						if (i != 0 || j != 0) && ok && world[ny][nx] {
+							n++
+						}
+					}
+				}
+				newWorld[y][x] = n == 3 || (n == 2 && world[y][x])
+			}
+		}
+		world = newWorld
+	}
+
+	var cells []util.Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// referenceNeighbour maps a position that may be just outside the board back onto the board.
+func referenceNeighbour(x, y int, p gol.Params) (int, int, bool) {
+	w, h := p.ImageWidth, p.ImageHeight
+	if x < 0 || x >= w {
+		if p.Topology == gol.Bounded {
+			return 0, 0, false
+		}
+		x = (x + w) % w
+	}
+	if y < 0 || y >= h {
+		switch p.Topology {
+		case gol.Torus:
+			y = (y + h) % h
+		case gol.KleinBottle:
+			x, y = w-1-x, (y+h)%h
+		default:
+			return 0, 0, false
+		}
+	}
+	return x, y, true
+}